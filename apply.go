@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/tskinn/envi/store"
+	"github.com/urfave/cli"
+)
+
+// printPlan prints the changes and reports whether there are any
+func printPlan(changes []store.Change) bool {
+	if len(changes) == 0 {
+		fmt.Println("no changes")
+		return false
+	}
+	for i := range changes {
+		fmt.Print(changes[i].String())
+	}
+	return true
+}
+
+func applyCommand() cli.Command {
+	var manifestPath string
+	var dryRun bool
+	command := cli.Command{
+		Name:  "apply",
+		Usage: "apply the application configurations declared in a manifest in a single transaction",
+		Action: func(c *cli.Context) error {
+			if manifestPath == "" {
+				return usageErrorf("must provide a path to a manifest")
+			}
+			file, err := os.Open(manifestPath)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			manifest, err := store.ParseManifest(file)
+			if err != nil {
+				return fmt.Errorf("error parsing manifest: %w", err)
+			}
+
+			if err := initStore(); err != nil {
+				return err
+			}
+			changes, err := store.Plan(manifest.Items, manifest.Prune)
+			if err != nil {
+				return err
+			}
+			if !printPlan(changes) || dryRun {
+				return nil
+			}
+			return store.ApplyChanges(changes)
+		},
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:        "file, f",
+				Value:       "",
+				Usage:       "path to the manifest",
+				Destination: &manifestPath,
+			},
+			cli.BoolFlag{
+				Name:        "dry-run",
+				Usage:       "only print the changes",
+				Destination: &dryRun,
+			},
+		},
+	}
+	return command
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/tskinn/envi/store"
+	"github.com/urfave/cli"
+)
+
+func auditCommand() cli.Command {
+	var since, output string
+	command := cli.Command{
+		Name:  "audit",
+		Usage: "show the audit events of an application configuration, or of all of them without an id",
+		Action: func(c *cli.Context) error {
+			if audit.File == "" && audit.Table == "" {
+				return usageErrorf("must provide an audit file or audit table")
+			}
+			start, err := parseSince(since, time.Now())
+			if err != nil {
+				return err
+			}
+			if output != "text" && output != "json" {
+				return usageErrorf("unknown output format %s", output)
+			}
+			if err := initStore(); err != nil {
+				return err
+			}
+			events, err := store.AuditEvents(id, start)
+			if err != nil {
+				return err
+			}
+			encoder := json.NewEncoder(os.Stdout)
+			for _, event := range events {
+				if output == "json" {
+					if err := encoder.Encode(event); err != nil {
+						return err
+					}
+					continue
+				}
+				fmt.Printf("%s %s %s by %s\n", event.Time.Format(time.RFC3339), event.Action, event.ID, event.Actor)
+				for _, change := range event.Changes {
+					fmt.Printf("  %s %s%s\n", change.Change, change.Name, changeValue(change))
+				}
+			}
+			return nil
+		},
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:        "since",
+				Value:       "24h",
+				Usage:       "show the events since a duration ago, e.g. 24h, or a time, e.g. 2006-01-02T15:04:05Z",
+				Destination: &since,
+			},
+			cli.StringFlag{
+				Name:        "output, o",
+				Value:       "text",
+				Usage:       "format of the events: text or json, one event per line",
+				Destination: &output,
+			},
+		},
+	}
+	return command
+}
+
+// parseSince returns the time a duration before now, or the time given
+// in RFC3339
+func parseSince(since string, now time.Time) (time.Time, error) {
+	if duration, err := time.ParseDuration(since); err == nil {
+		return now.Add(-duration), nil
+	}
+	if t, err := time.Parse(time.RFC3339, since); err == nil {
+		return t, nil
+	}
+	return time.Time{}, usageErrorf("since must be a duration or a time in RFC3339, got %s", since)
+}
+
+// changeValue returns how the new value of a change is shown
+func changeValue(change store.AuditChange) string {
+	switch {
+	case change.Hash != "":
+		return " hmac:" + change.Hash
+	case change.Secret:
+		return " (secret)"
+	case change.Change != store.ChangeRemoved:
+		return "=" + change.Value
+	}
+	return ""
+}
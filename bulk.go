@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/tskinn/envi/store"
+	"github.com/urfave/cli"
+)
+
+func exportCommand() cli.Command {
+	var outPath, prefix string
+	command := cli.Command{
+		Name:  "export",
+		Usage: "export every application configuration in the table as json lines",
+		Action: func(c *cli.Context) error {
+			if err := initStore(); err != nil {
+				return err
+			}
+			// --out is only truncated once the store can be read
+			var out io.Writer = os.Stdout
+			if outPath != "" && outPath != "-" {
+				file, err := os.OpenFile(outPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+				if err != nil {
+					return err
+				}
+				defer file.Close()
+				// the mode of an existing file isn't changed by OpenFile
+				if err := file.Chmod(0600); err != nil {
+					return err
+				}
+				out = file
+			}
+			count, err := store.Export(out, prefix)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "exported %d configs\n", count)
+			return nil
+		},
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:        "out",
+				Value:       "",
+				Usage:       "path to write the export to; defaults to stdout",
+				Destination: &outPath,
+			},
+			cli.StringFlag{
+				Name:        "prefix",
+				Value:       "",
+				Usage:       "only export ids starting with prefix, e.g. app__ or app__prod",
+				Destination: &prefix,
+			},
+		},
+	}
+	return command
+}
+
+func importCommand() cli.Command {
+	var merge bool
+	command := cli.Command{
+		Name:      "import",
+		Usage:     "import application configurations written by export, overwriting configs with the same id unless --merge is given",
+		ArgsUsage: "<file or - for stdin>",
+		Action: func(c *cli.Context) error {
+			inPath := c.Args().First()
+			if inPath == "" {
+				return usageErrorf("must provide a path to a file to import")
+			}
+			var in io.Reader = os.Stdin
+			if inPath != "-" {
+				file, err := os.Open(inPath)
+				if err != nil {
+					return err
+				}
+				defer file.Close()
+				in = file
+			}
+
+			if err := initStore(); err != nil {
+				return err
+			}
+			count, err := store.ImportWithOptions(in, store.ImportOptions{Merge: merge})
+			fmt.Fprintf(os.Stderr, "imported %d configs\n", count)
+			return err
+		},
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:        "merge",
+				Usage:       "insert new vars and update existing vars of configs with the same id like update instead of overwriting them",
+				Destination: &merge,
+			},
+		},
+	}
+	return command
+}
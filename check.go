@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/tskinn/envi/store"
+	"github.com/urfave/cli"
+)
+
+// errDrift is returned by check when the config doesn't match
+var errDrift = errors.New("drift detected")
+
+func checkCommand() cli.Command {
+	var againstFile, format string
+	var againstEnv bool
+	command := cli.Command{
+		Name:  "check",
+		Usage: "check that a file or the current environment matches the application configuration",
+		Action: func(c *cli.Context) error {
+			if id == "" {
+				return usageErrorf("must provide id")
+			}
+			if (againstFile == "") == !againstEnv {
+				return usageErrorf("must provide one of against-file or against-env")
+			}
+
+			var actual []store.Variable
+			if againstEnv {
+				actual = store.ParseEnviron(os.Environ())
+			} else {
+				var err error
+				actual, err = store.ParseFileUnlinted(againstFile, format)
+				if err != nil {
+					return err
+				}
+			}
+			if err := initStore(); err != nil {
+				return err
+			}
+			item, err := store.Get(id)
+			if err != nil {
+				return err
+			}
+
+			drift := store.CheckDrift(item.Variables, actual, againstEnv)
+			if drift.Empty() {
+				return nil
+			}
+			fmt.Print(drift.String())
+			return errDrift
+		},
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:        "against-file",
+				Value:       "",
+				Usage:       "path to a file containing env vars or - for stdin",
+				Destination: &againstFile,
+			},
+			cli.StringFlag{
+				Name:        "format",
+				Value:       "",
+				Usage:       "format of the file: env, json, ecs, yaml or docker; detected if not provided",
+				Destination: &format,
+			},
+			cli.BoolFlag{
+				Name:        "against-env",
+				Usage:       "check the environment of the current process",
+				Destination: &againstEnv,
+			},
+		},
+	}
+	return command
+}
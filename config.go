@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/urfave/cli"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// repoConfigName is the name of the config file of a repository. It is
+// looked for in the working directory and its parents.
+const repoConfigName = ".envi.yaml"
+
+// profileName is the profile selected with --profile
+var profileName string
+
+// profile is the selected profile, set before a command using the table
+// runs
+var profile Profile
+
+// Config is the content of a config file:
+//
+//	default_profile: dev
+//	profiles:
+//	  dev:
+//	    table: envi-dev
+//	    region: us-west-2
+//	    app: omega
+//	    output: json
+type Config struct {
+	// DefaultProfile is used if no profile is selected. The profile
+	// named default is used otherwise, if there is one.
+	DefaultProfile string             `yaml:"default_profile"`
+	Profiles       map[string]Profile `yaml:"profiles"`
+}
+
+// Profile holds the values used for the flags that aren't given
+type Profile struct {
+	// Backend is where configs are stored. Only dynamodb is supported.
+	Backend    string `yaml:"backend"`
+	Table      string `yaml:"table"`
+	Region     string `yaml:"region"`
+	Endpoint   string `yaml:"endpoint"`
+	AWSProfile string `yaml:"aws_profile"`
+	RoleARN    string `yaml:"role_arn"`
+	ExternalID string `yaml:"external_id"`
+	MFASerial  string `yaml:"mfa_serial"`
+	AuditFile  string `yaml:"audit_file"`
+	AuditTable string `yaml:"audit_table"`
+	AuditActor string `yaml:"audit_actor"`
+	// App is prepended to ids without an application, so -i prod means
+	// <app>__prod
+	App string `yaml:"app"`
+	// Output is the default output format of get
+	Output string `yaml:"output"`
+}
+
+// merge returns the profile with the fields set in other replaced
+func (p Profile) merge(other Profile) Profile {
+	set := func(field *string, value string) {
+		if value != "" {
+			*field = value
+		}
+	}
+	set(&p.Backend, other.Backend)
+	set(&p.Table, other.Table)
+	set(&p.Region, other.Region)
+	set(&p.Endpoint, other.Endpoint)
+	set(&p.AWSProfile, other.AWSProfile)
+	set(&p.RoleARN, other.RoleARN)
+	set(&p.ExternalID, other.ExternalID)
+	set(&p.MFASerial, other.MFASerial)
+	set(&p.AuditFile, other.AuditFile)
+	set(&p.AuditTable, other.AuditTable)
+	set(&p.AuditActor, other.AuditActor)
+	set(&p.App, other.App)
+	set(&p.Output, other.Output)
+	return p
+}
+
+// configPaths returns the paths of the config files that exist, the
+// user's first and the repository's last so that it takes precedence
+func configPaths() []string {
+	var paths []string
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			dir = filepath.Join(home, ".config")
+		}
+	}
+	if dir != "" {
+		path := filepath.Join(dir, "envi", "config.yaml")
+		if _, err := os.Stat(path); err == nil {
+			paths = append(paths, path)
+		}
+	}
+	if dir, err := os.Getwd(); err == nil {
+		for {
+			path := filepath.Join(dir, repoConfigName)
+			if _, err := os.Stat(path); err == nil {
+				paths = append(paths, path)
+				break
+			}
+			parent := filepath.Dir(dir)
+			if parent == dir {
+				break
+			}
+			dir = parent
+		}
+	}
+	return paths
+}
+
+// loadConfig reads and merges the config files
+func loadConfig(paths []string) (Config, error) {
+	config := Config{Profiles: make(map[string]Profile)}
+	for _, path := range paths {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return config, err
+		}
+		var file Config
+		decoder := yaml.NewDecoder(bytes.NewReader(content))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&file); err != nil && err != io.EOF {
+			return config, usageErrorf("%s: %s", path, err)
+		}
+		if file.DefaultProfile != "" {
+			config.DefaultProfile = file.DefaultProfile
+		}
+		for name, p := range file.Profiles {
+			config.Profiles[name] = config.Profiles[name].merge(p)
+		}
+	}
+	return config, nil
+}
+
+// selectProfile returns the profile named name, or the default one if
+// name is empty
+func selectProfile(config Config, name string) (Profile, error) {
+	if name == "" {
+		name = config.DefaultProfile
+	}
+	var p Profile
+	if name == "" {
+		// a missing default profile is the same as an empty one
+		name = "default"
+		p = config.Profiles[name]
+	} else {
+		var found bool
+		if p, found = config.Profiles[name]; !found {
+			return p, usageErrorf("there is no profile named %s", name)
+		}
+	}
+	if p.Backend != "" && p.Backend != "dynamodb" {
+		return p, usageErrorf("profile %s: unknown backend %s", name, p.Backend)
+	}
+	return p, nil
+}
+
+// applyProfile fills in the table flags, and the id, that weren't given
+// from the selected profile. Flags and their environment variables take
+// precedence over the profile.
+func applyProfile(c *cli.Context) error {
+	config, err := loadConfig(configPaths())
+	if err != nil {
+		return err
+	}
+	// --profile selected an aws profile before there were envi profiles
+	awsProfile := ""
+	if _, found := config.Profiles[profileName]; profileName != "" && !found && !c.IsSet("aws-profile") {
+		fmt.Fprintf(os.Stderr, "warning: there is no envi profile named %s so it is used as the aws profile; use --aws-profile instead, --profile will only select envi profiles in a future release\n", profileName)
+		awsProfile, profileName = profileName, ""
+	}
+	if profile, err = selectProfile(config, profileName); err != nil {
+		return err
+	}
+	fill := func(flag string, field *string, value string) {
+		if value != "" && !c.IsSet(flag) {
+			*field = value
+		}
+	}
+	fill("table", &tableName, profile.Table)
+	fill("region", &awsRegion, profile.Region)
+	fill("endpoint", &awsOptions.Endpoint, profile.Endpoint)
+	fill("aws-profile", &awsOptions.Profile, profile.AWSProfile)
+	fill("role-arn", &awsOptions.RoleARN, profile.RoleARN)
+	fill("external-id", &awsOptions.ExternalID, profile.ExternalID)
+	fill("mfa-serial", &awsOptions.MFASerial, profile.MFASerial)
+	fill("audit-file", &audit.File, profile.AuditFile)
+	fill("audit-table", &audit.Table, profile.AuditTable)
+	fill("audit-actor", &audit.Actor, profile.AuditActor)
+	if awsProfile != "" {
+		awsOptions.Profile = awsProfile
+	}
+	id = profileID(id)
+	return nil
+}
+
+// profileID prepends the app of the selected profile to an id without
+// an application
+func profileID(id string) string {
+	if profile.App != "" && id != "" && !strings.Contains(id, "__") {
+		return fmt.Sprintf("%s__%s", profile.App, id)
+	}
+	return id
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/urfave/cli"
+)
+
+const testUserConfig = `default_profile: dev
+profiles:
+  dev:
+    table: envi-dev
+    region: us-west-2
+    app: omega
+  prod:
+    table: envi
+    aws_profile: ops
+    app: omega
+`
+
+const testRepoConfig = `profiles:
+  dev:
+    table: envi-repo
+`
+
+// writeConfigs writes the user's config file and the config file of a
+// repository in the working directory, which is changed to a temporary
+// directory for the rest of the test
+func writeConfigs(t *testing.T, user, repo string) {
+	home := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", home)
+	if user != "" {
+		if err := os.MkdirAll(filepath.Join(home, "envi"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(home, "envi", "config.yaml"), []byte(user), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	repoDir := t.TempDir()
+	if repo != "" {
+		if err := ioutil.WriteFile(filepath.Join(repoDir, repoConfigName), []byte(repo), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	user := write("user.yaml", testUserConfig)
+	repo := write("repo.yaml", testRepoConfig+"default_profile: prod\n")
+	empty := write("empty.yaml", "")
+	unknown := write("unknown.yaml", "profiles:\n  dev:\n    tabel: envi\n")
+
+	tests := []struct {
+		name           string
+		paths          []string
+		defaultProfile string
+		dev            Profile
+		err            bool
+	}{
+		{"none", nil, "", Profile{}, false},
+		{"empty", []string{empty}, "", Profile{}, false},
+		{"user", []string{user}, "dev", Profile{Table: "envi-dev", Region: "us-west-2", App: "omega"}, false},
+		{"repo overrides user", []string{user, repo}, "prod", Profile{Table: "envi-repo", Region: "us-west-2", App: "omega"}, false},
+		{"unknown field", []string{unknown}, "", Profile{}, true},
+	}
+	for _, test := range tests {
+		config, err := loadConfig(test.paths)
+		if (err != nil) != test.err {
+			t.Fatalf("%s: unexpected error %v", test.name, err)
+		}
+		if err != nil {
+			if exitCode(err) != exitUsage {
+				t.Fatalf("%s: expected a usage error, got %v", test.name, err)
+			}
+			continue
+		}
+		if config.DefaultProfile != test.defaultProfile || config.Profiles["dev"] != test.dev {
+			t.Fatalf("%s: unexpected config %+v", test.name, config)
+		}
+	}
+}
+
+func TestSelectProfile(t *testing.T) {
+	dev := Profile{Table: "envi-dev"}
+	def := Profile{Table: "envi-default"}
+	tests := []struct {
+		name    string
+		config  Config
+		profile string
+		want    Profile
+		err     bool
+	}{
+		{"named", Config{Profiles: map[string]Profile{"dev": dev}}, "dev", dev, false},
+		{"default profile", Config{DefaultProfile: "dev", Profiles: map[string]Profile{"dev": dev, "default": def}}, "", dev, false},
+		{"profile named default", Config{Profiles: map[string]Profile{"dev": dev, "default": def}}, "", def, false},
+		{"no profiles", Config{}, "", Profile{}, false},
+		{"missing", Config{Profiles: map[string]Profile{"dev": dev}}, "prod", Profile{}, true},
+		{"missing default profile", Config{DefaultProfile: "prod"}, "", Profile{}, true},
+		{"unknown backend", Config{Profiles: map[string]Profile{"dev": {Backend: "consul"}}}, "dev", Profile{}, true},
+	}
+	for _, test := range tests {
+		p, err := selectProfile(test.config, test.profile)
+		if (err != nil) != test.err {
+			t.Fatalf("%s: unexpected error %v", test.name, err)
+		}
+		if err == nil && p != test.want {
+			t.Fatalf("%s: expected %+v, got %+v", test.name, test.want, p)
+		}
+	}
+}
+
+func TestMergeProfiles(t *testing.T) {
+	tests := []struct {
+		name       string
+		one, other Profile
+		want       Profile
+	}{
+		{"empty other", Profile{Table: "one", App: "omega"}, Profile{}, Profile{Table: "one", App: "omega"}},
+		{"other overrides", Profile{Table: "one", Region: "us-east-1"}, Profile{Table: "two"}, Profile{Table: "two", Region: "us-east-1"}},
+		{"other adds", Profile{Table: "one"}, Profile{Output: "json", AuditActor: "me"}, Profile{Table: "one", Output: "json", AuditActor: "me"}},
+	}
+	for _, test := range tests {
+		if merged := test.one.merge(test.other); merged != test.want {
+			t.Fatalf("%s: expected %+v, got %+v", test.name, test.want, merged)
+		}
+	}
+}
+
+func TestApplyProfile(t *testing.T) {
+	tests := []struct {
+		name       string
+		env        map[string]string
+		args       []string
+		table      string
+		region     string
+		awsProfile string
+		id         string
+		err        bool
+	}{
+		{"default profile", nil, []string{"-i", "prod"}, "envi-repo", "us-west-2", "", "omega__prod", false},
+		{"id with app", nil, []string{"-i", "other__prod"}, "envi-repo", "us-west-2", "", "other__prod", false},
+		{"env over profile", map[string]string{"ENVI_TABLE": "from-env"}, []string{"-i", "prod"}, "from-env", "us-west-2", "", "omega__prod", false},
+		{"flag over env", map[string]string{"ENVI_TABLE": "from-env"}, []string{"-t", "from-flag", "-i", "prod"}, "from-flag", "us-west-2", "", "omega__prod", false},
+		{"named profile", nil, []string{"--profile", "prod"}, "envi", "us-east-1", "ops", "", false},
+		{"profile from env", map[string]string{"ENVI_PROFILE": "prod"}, nil, "envi", "us-east-1", "ops", "", false},
+		{"aws profile flag over profile", nil, []string{"--profile", "prod", "--aws-profile", "admin"}, "envi", "us-east-1", "admin", "", false},
+		{"legacy aws profile", nil, []string{"--profile", "legacy"}, "envi-repo", "us-west-2", "legacy", "", false},
+		{"missing profile", nil, []string{"--profile", "legacy", "--aws-profile", "admin"}, "", "", "", "", true},
+	}
+	writeConfigs(t, testUserConfig, testRepoConfig)
+	for _, test := range tests {
+		for name, value := range test.env {
+			os.Setenv(name, value)
+		}
+		app := cli.NewApp()
+		app.Writer = ioutil.Discard
+		app.ErrWriter = ioutil.Discard
+		app.Commands = []cli.Command{configCommand(cli.Command{
+			Name:   "test",
+			Action: func(c *cli.Context) error { return nil },
+		})}
+		err := app.Run(append([]string{"envi", "test"}, test.args...))
+		for name := range test.env {
+			os.Unsetenv(name)
+		}
+		if (err != nil) != test.err {
+			t.Fatalf("%s: unexpected error %v", test.name, err)
+		}
+		if err != nil {
+			continue
+		}
+		if tableName != test.table || awsRegion != test.region || awsOptions.Profile != test.awsProfile || id != test.id {
+			t.Fatalf("%s: unexpected table %s, region %s, aws profile %s and id %s", test.name, tableName, awsRegion, awsOptions.Profile, id)
+		}
+	}
+}
+
+func TestProfileID(t *testing.T) {
+	defer func(p Profile) { profile = p }(profile)
+	tests := []struct {
+		app, id, want string
+	}{
+		{"", "prod", "prod"},
+		{"omega", "prod", "omega__prod"},
+		{"omega", "other__prod", "other__prod"},
+		{"omega", "", ""},
+	}
+	for _, test := range tests {
+		profile = Profile{App: test.app}
+		if got := profileID(test.id); got != test.want {
+			t.Fatalf("expected %s with app %q to be %s, got %s", test.id, test.app, test.want, got)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/tskinn/envi/store"
+	"github.com/urfave/cli"
+)
+
+// splitList splits a comma separated flag value ignoring empty elements
+func splitList(list string) []string {
+	elements := make([]string, 0)
+	for _, element := range strings.Split(list, ",") {
+		if element = strings.TrimSpace(element); element != "" {
+			elements = append(elements, element)
+		}
+	}
+	return elements
+}
+
+func copyCommand() cli.Command {
+	var from, to, include, exclude string
+	var merge, replace, onlyMissing bool
+	command := cli.Command{
+		Name:    "copy",
+		Aliases: []string{"cp"},
+		Usage:   "copy the variables of one application configuration to another",
+		Action: func(c *cli.Context) error {
+			if from == "" || to == "" {
+				return usageErrorf("must provide from and to ids")
+			}
+			if merge && replace {
+				return usageErrorf("must provide only one of merge or replace")
+			}
+
+			if err := initStore(); err != nil {
+				return err
+			}
+			return store.Copy(profileID(from), profileID(to), store.CopyOptions{
+				Replace:     replace,
+				OnlyMissing: onlyMissing,
+				Include:     splitList(include),
+				Exclude:     splitList(exclude),
+			})
+		},
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:        "from",
+				Value:       "",
+				Usage:       "id of the application configuration to copy from; prefixed like --id",
+				Destination: &from,
+			},
+			cli.StringFlag{
+				Name:        "to",
+				Value:       "",
+				Usage:       "id of the application configuration to copy to; prefixed like --id",
+				Destination: &to,
+			},
+			cli.BoolFlag{
+				Name:        "merge",
+				Usage:       "insert new vars and update existing vars of the destination like update (default)",
+				Destination: &merge,
+			},
+			cli.BoolFlag{
+				Name:        "replace",
+				Usage:       "replace all vars of the destination like set",
+				Destination: &replace,
+			},
+			cli.BoolFlag{
+				Name:        "only-missing",
+				Usage:       "only copy vars the destination doesn't have",
+				Destination: &onlyMissing,
+			},
+			cli.StringFlag{
+				Name:        "include",
+				Value:       "",
+				Usage:       "only copy vars matching these comma separated patterns, e.g. DB_*,PORT",
+				Destination: &include,
+			},
+			cli.StringFlag{
+				Name:        "exclude",
+				Value:       "",
+				Usage:       "don't copy vars matching these comma separated patterns",
+				Destination: &exclude,
+			},
+		},
+	}
+	return command
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/tskinn/envi/store"
+	"github.com/urfave/cli"
+)
+
+func dumpCommand() cli.Command {
+	var dir, format, prefix, secretPatterns, maskKey string
+	var reveal, prune bool
+	command := cli.Command{
+		Name:  "dump",
+		Usage: "write every application configuration to a directory of config files",
+		Action: func(c *cli.Context) error {
+			if dir == "" {
+				return usageErrorf("must provide a directory")
+			}
+
+			if err := initStore(); err != nil {
+				return err
+			}
+			count, err := store.Dump(dir, prefix, store.DumpOptions{
+				Format:         format,
+				Reveal:         reveal,
+				SecretPatterns: splitList(secretPatterns),
+				Prune:          prune,
+				MaskKey:        []byte(maskKey),
+			})
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "dumped %d configs\n", count)
+			return nil
+		},
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:        "dir, d",
+				Value:       "",
+				Usage:       "directory to write the config files to",
+				Destination: &dir,
+			},
+			cli.StringFlag{
+				Name:        "format",
+				Value:       "env",
+				Usage:       "format of the config files: env or yaml",
+				Destination: &format,
+			},
+			cli.StringFlag{
+				Name:        "prefix",
+				Value:       "",
+				Usage:       "only dump ids starting with prefix, e.g. app__",
+				Destination: &prefix,
+			},
+			cli.StringFlag{
+				Name:        "secret-patterns",
+				Value:       "",
+				Usage:       "comma separated patterns matching the names of secrets (default: *SECRET*,*PASSWORD*,*PASSWD*,*TOKEN*,*PRIVATE_KEY*,*API_KEY*,*CREDENTIAL*)",
+				Destination: &secretPatterns,
+			},
+			cli.StringFlag{
+				Name:        "mask-key",
+				Value:       "",
+				Usage:       "key of the HMACs secrets are masked with, so changed secrets show up in diffs (default: mask every secret the same)",
+				EnvVar:      "ENVI_MASK_KEY",
+				Destination: &maskKey,
+			},
+			cli.BoolFlag{
+				Name:        "reveal",
+				Usage:       "write secrets as they are instead of masking them",
+				Destination: &reveal,
+			},
+			cli.BoolFlag{
+				Name:        "prune",
+				Usage:       "remove config files of configs that no longer exist",
+				Destination: &prune,
+			},
+		},
+	}
+	return command
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/tskinn/envi/store"
+	"github.com/urfave/cli"
+)
+
+func renderECSCommand() cli.Command {
+	var taskDefPath, container, outPath string
+	command := cli.Command{
+		Name:  "render-ecs",
+		Usage: "inject the application configuration into the environment and secrets of an ecs task definition",
+		Action: func(c *cli.Context) error {
+			if id == "" {
+				return usageErrorf("must provide id")
+			}
+			if taskDefPath == "" {
+				return usageErrorf("must provide a path to a task definition")
+			}
+
+			taskDef, err := ioutil.ReadFile(taskDefPath)
+			if err != nil {
+				return err
+			}
+			if err := initStore(); err != nil {
+				return err
+			}
+			item, err := store.Get(id)
+			if err != nil {
+				return err
+			}
+			rendered, err := item.RenderECSTaskDefinition(taskDef, container)
+			if err != nil {
+				return err
+			}
+			if outPath == "" {
+				_, err = os.Stdout.Write(rendered)
+				return err
+			}
+			return store.WritePrivateFile(outPath, rendered)
+		},
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:        "task-def",
+				Value:       "",
+				Usage:       "path to the task definition json file",
+				Destination: &taskDefPath,
+			},
+			cli.StringFlag{
+				Name:        "container, c",
+				Value:       "",
+				Usage:       "name of the container definition to inject variables into; optional if there is only one",
+				Destination: &container,
+			},
+			cli.StringFlag{
+				Name:        "out",
+				Value:       "",
+				Usage:       "path to write the rendered task definition to; defaults to stdout",
+				Destination: &outPath,
+			},
+		},
+	}
+	return command
+}
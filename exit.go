@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/tskinn/envi/store"
+	"github.com/urfave/cli"
+)
+
+// Exit codes of the cli. Scripts can rely on them not changing.
+const (
+	exitError      = 1
+	exitUsage      = 2
+	exitNotFound   = 3
+	exitConflict   = 4
+	exitAuth       = 5
+	exitValidation = 6
+	exitBackend    = 7
+)
+
+// kinds of errors written by --error-format json, by exit code
+var errorKinds = map[int]string{
+	exitError:      "error",
+	exitUsage:      "usage",
+	exitNotFound:   "not_found",
+	exitConflict:   "conflict",
+	exitAuth:       "auth",
+	exitValidation: "validation",
+	exitBackend:    "backend_unavailable",
+}
+
+// errorFormat is the format errors are written in: text or json
+var errorFormat string
+
+// usageError is an error in how a command was called, e.g. a missing
+// flag
+type usageError struct {
+	message string
+}
+
+func (e *usageError) Error() string {
+	return e.message
+}
+
+func usageErrorf(format string, args ...interface{}) error {
+	return &usageError{message: fmt.Sprintf(format, args...)}
+}
+
+// commandExit is the non-zero exit status of a command started by
+// envi run, which envi exits with too
+type commandExit struct {
+	code int
+}
+
+func (e *commandExit) Error() string {
+	return fmt.Sprintf("command exited with status %d", e.code)
+}
+
+// onUsageError is called by cli when flags can't be parsed
+func onUsageError(c *cli.Context, err error, isSubcommand bool) error {
+	return &usageError{message: err.Error()}
+}
+
+// awsAuthCodes are the codes of aws errors caused by missing or invalid
+// credentials or permissions
+var awsAuthCodes = map[string]bool{
+	"AccessDenied":                true,
+	"AccessDeniedException":       true,
+	"ExpiredTokenException":       true,
+	"InvalidClientTokenId":        true,
+	"InvalidSignatureException":   true,
+	"NoCredentialProviders":       true,
+	"SharedCredsLoad":             true,
+	"UnrecognizedClientException": true,
+}
+
+// awsBackendCodes are the codes of aws errors meaning the table can't be
+// used right now
+var awsBackendCodes = map[string]bool{
+	request.ErrCodeRequestError:                            true,
+	request.ErrCodeResponseTimeout:                         true,
+	dynamodb.ErrCodeResourceNotFoundException:              true,
+	dynamodb.ErrCodeProvisionedThroughputExceededException: true,
+	dynamodb.ErrCodeRequestLimitExceeded:                   true,
+	dynamodb.ErrCodeInternalServerError:                    true,
+	"ThrottlingException":                                  true,
+	"ServiceUnavailable":                                   true,
+}
+
+// exitCode returns the exit code for err
+func exitCode(err error) int {
+	var usage *usageError
+	var command *commandExit
+	var aerr awserr.Error
+	switch {
+	case errors.As(err, &command):
+		return command.code
+	case errors.As(err, &usage):
+		return exitUsage
+	case errors.Is(err, store.ErrNotFound):
+		return exitNotFound
+	case errors.Is(err, store.ErrConflict):
+		return exitConflict
+	case errors.Is(err, store.ErrValidation):
+		return exitValidation
+	case errors.As(err, &aerr) && awsAuthCodes[aerr.Code()]:
+		return exitAuth
+	case errors.As(err, &aerr) && awsBackendCodes[aerr.Code()]:
+		return exitBackend
+	}
+	return exitError
+}
+
+type errorOutput struct {
+	Error string `json:"error"`
+	Kind  string `json:"kind"`
+	Code  int    `json:"code"`
+}
+
+// writeError writes err to w in errorFormat and returns the exit code
+func writeError(w io.Writer, err error) int {
+	code := exitCode(err)
+	// the command has already reported its own errors
+	if _, ok := err.(*commandExit); ok {
+		return code
+	}
+	kind := errorKinds[code]
+	if err == errDrift {
+		kind = "drift"
+	}
+	if errorFormat == "json" {
+		encoder := json.NewEncoder(w)
+		encoder.SetEscapeHTML(false)
+		encoder.Encode(errorOutput{Error: err.Error(), Kind: kind, Code: code})
+		return code
+	}
+	fmt.Fprintln(w, err)
+	return code
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/tskinn/envi/store"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		code int
+	}{
+		{"error", errors.New("boom"), exitError},
+		{"usage", usageErrorf("must provide id"), exitUsage},
+		{"wrapped usage", fmt.Errorf("set: %w", usageErrorf("must provide id")), exitUsage},
+		{"not found", fmt.Errorf("app__prod: %w", store.ErrNotFound), exitNotFound},
+		{"conflict", fmt.Errorf("app__prod: %w", store.ErrConflict), exitConflict},
+		{"validation", fmt.Errorf("PORT: %w", store.ErrValidation), exitValidation},
+		{"auth", awserr.New("AccessDeniedException", "not allowed", nil), exitAuth},
+		{"credentials", awserr.New("NoCredentialProviders", "no credentials", nil), exitAuth},
+		{"backend", awserr.New(dynamodb.ErrCodeProvisionedThroughputExceededException, "slow down", nil), exitBackend},
+		{"missing table", awserr.New(dynamodb.ErrCodeResourceNotFoundException, "no table", nil), exitBackend},
+		{"other aws error", awserr.New("SomethingElse", "huh", nil), exitError},
+		{"command exit", &commandExit{code: 42}, 42},
+	}
+	for _, test := range tests {
+		if code := exitCode(test.err); code != test.code {
+			t.Fatalf("%s: expected exit code %d, got %d", test.name, test.code, code)
+		}
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	defer func(format string) { errorFormat = format }(errorFormat)
+	tests := []struct {
+		name   string
+		format string
+		err    error
+		code   int
+		output string
+	}{
+		{"text", "text", usageErrorf("must provide id"), exitUsage, "must provide id\n"},
+		{"json", "json", fmt.Errorf("app__prod: %w", store.ErrNotFound), exitNotFound, `{"error":"app__prod: not found","kind":"not_found","code":3}` + "\n"},
+		{"json keeps html", "json", errors.New("<nil> & more"), exitError, `{"error":"<nil> & more","kind":"error","code":1}` + "\n"},
+		{"drift", "json", errDrift, exitError, `{"error":"drift detected","kind":"drift","code":1}` + "\n"},
+		{"command exit", "json", &commandExit{code: 42}, 42, ""},
+	}
+	for _, test := range tests {
+		errorFormat = test.format
+		var buffer bytes.Buffer
+		if code := writeError(&buffer, test.err); code != test.code {
+			t.Fatalf("%s: expected exit code %d, got %d", test.name, test.code, code)
+		}
+		if buffer.String() != test.output {
+			t.Fatalf("%s: expected output %q, got %q", test.name, test.output, buffer.String())
+		}
+		if test.format == "json" && test.output != "" {
+			var output errorOutput
+			if err := json.Unmarshal(buffer.Bytes(), &output); err != nil || output.Code != test.code {
+				t.Fatalf("%s: unexpected json %s %v", test.name, buffer.String(), err)
+			}
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/tskinn/envi/store"
+	"github.com/urfave/cli"
+)
+
+// contentType is the content type of the files given with --file-var
+var contentType string
+
+// fileVarFlags are the flags of the commands that store the contents of
+// files as variables
+var fileVarFlags = []cli.Flag{
+	cli.StringSliceFlag{
+		Name:  "file-var",
+		Usage: "store the contents of a file as a variable in the form of NAME=path; may be repeated",
+	},
+	cli.StringFlag{
+		Name:        "content-type",
+		Value:       "",
+		Usage:       "content type of the files given with --file-var; guessed if not provided",
+		Destination: &contentType,
+	},
+}
+
+// withFileVars returns the variables given with --variables or --file
+// followed by a variable for each file given with --file-var
+func withFileVars(variables, filePath, format string, fileVars []string) ([]store.Variable, error) {
+	var vars []store.Variable
+	var err error
+	if filePath != "" {
+		vars, err = store.ParseFile(filePath, format)
+	} else if variables != "" {
+		vars, err = store.ParseVariables(variables)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, fileVar := range fileVars {
+		parts := strings.SplitN(fileVar, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, usageErrorf("bad file variable %q; must be NAME=path", fileVar)
+		}
+		variable, err := store.ReadFileVariable(parts[0], parts[1], contentType)
+		if err != nil {
+			return nil, err
+		}
+		vars = append(vars, variable)
+	}
+	return vars, nil
+}
+
+// hasFileVars reports whether the item has a variable holding the
+// contents of a file
+func hasFileVars(item store.Item) bool {
+	for _, variable := range item.Variables {
+		if variable.IsFile() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+// Package storetest provides a store backed by an in memory table for
+// the tests of the packages serving the store
+package storetest
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/tskinn/envi/store"
+)
+
+// DB is an in memory table supporting the calls made by the store for
+// items small enough to not be chunked
+type DB struct {
+	dynamodbiface.DynamoDBAPI
+	// Items are the rows of the table by id
+	Items map[string]map[string]*dynamodb.AttributeValue
+}
+
+func (m DB) GetItemWithContext(ctx aws.Context, input *dynamodb.GetItemInput, opts ...request.Option) (*dynamodb.GetItemOutput, error) {
+	return &dynamodb.GetItemOutput{Item: m.Items[*input.Key["id"].S]}, nil
+}
+
+func (m DB) PutItemWithContext(ctx aws.Context, input *dynamodb.PutItemInput, opts ...request.Option) (*dynamodb.PutItemOutput, error) {
+	m.Items[*input.Item["id"].S] = input.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (m DB) DeleteItemWithContext(ctx aws.Context, input *dynamodb.DeleteItemInput, opts ...request.Option) (*dynamodb.DeleteItemOutput, error) {
+	delete(m.Items, *input.Key["id"].S)
+	return &dynamodb.DeleteItemOutput{}, nil
+}
+
+func (m DB) ScanWithContext(ctx aws.Context, input *dynamodb.ScanInput, opts ...request.Option) (*dynamodb.ScanOutput, error) {
+	output := &dynamodb.ScanOutput{}
+	for id, item := range m.Items {
+		if input.FilterExpression == nil || strings.HasPrefix(id, *input.ExpressionAttributeValues[":prefix"].S) {
+			output.Items = append(output.Items, item)
+		}
+	}
+	return output, nil
+}
+
+// NewClient returns a store client of an empty in memory table
+func NewClient(t testing.TB) (*store.Client, DB) {
+	db := DB{Items: map[string]map[string]*dynamodb.AttributeValue{}}
+	client, err := store.NewClient(store.WithTable("envi"), store.WithDB(db))
+	if err != nil {
+		t.Fatalf("error creating store client %s", err)
+	}
+	return client, db
+}
+
+// SaveItem saves an item with variables given as name=value pairs
+func SaveItem(t testing.TB, client *store.Client, id string, pairs ...string) {
+	vars := make([]store.Variable, len(pairs))
+	for i, pair := range pairs {
+		parts := strings.SplitN(pair, "=", 2)
+		vars[i] = store.Variable{Name: parts[0], Value: parts[1]}
+	}
+	if err := client.Save(context.Background(), store.CreateItem(id, vars)); err != nil {
+		t.Fatalf("error saving %s %s", id, err)
+	}
+}
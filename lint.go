@@ -0,0 +1,56 @@
+package main
+
+import (
+	"github.com/tskinn/envi/store"
+	"github.com/urfave/cli"
+)
+
+var (
+	uppercaseNames    bool
+	forbiddenPrefixes string
+	maxValueLength    int
+	noLint            bool
+)
+
+// lintFlags configure the rules checked on variables before they are
+// saved
+var lintFlags = []cli.Flag{
+	cli.BoolFlag{
+		Name:        "uppercase-names",
+		Usage:       "require variable names to be uppercase",
+		EnvVar:      "ENVI_UPPERCASE_NAMES",
+		Destination: &uppercaseNames,
+	},
+	cli.StringFlag{
+		Name:        "forbidden-prefixes",
+		Value:       "",
+		Usage:       "comma separated prefixes variable names can't start with, e.g. AWS_,LD_",
+		EnvVar:      "ENVI_FORBIDDEN_PREFIXES",
+		Destination: &forbiddenPrefixes,
+	},
+	cli.IntFlag{
+		Name:        "max-value-length",
+		Value:       0,
+		Usage:       "maximum length of a value in bytes; 0 for no maximum",
+		EnvVar:      "ENVI_MAX_VALUE_LENGTH",
+		Destination: &maxValueLength,
+	},
+	cli.BoolFlag{
+		Name:        "no-lint",
+		Usage:       "don't check variable names and values before saving them",
+		EnvVar:      "ENVI_NO_LINT",
+		Destination: &noLint,
+	},
+}
+
+// lintRules returns the rules set by the lint flags
+func lintRules() store.LintRules {
+	if noLint {
+		return store.LintRules{}
+	}
+	rules := store.DefaultLintRules
+	rules.Uppercase = uppercaseNames
+	rules.ForbiddenPrefixes = splitList(forbiddenPrefixes)
+	rules.MaxValueLength = maxValueLength
+	return rules
+}
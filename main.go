@@ -1,15 +1,157 @@
 package main
 
 import (
-	"fmt"
+	"errors"
 	"os"
 
 	"github.com/tskinn/envi/store"
 	"github.com/urfave/cli"
 )
 
+var tableName, awsRegion, id string
+
+// awsOptions are set by the table flags
+var awsOptions store.AWSOptions
+
+// audit is set by the audit flags
+var audit store.Audit
+
+// auditHashKey and auditSecretPatterns are set by the audit flags and
+// filled into audit by auditOptions
+var auditHashKey, auditSecretPatterns string
+
+// tableFlags are the flags needed by every command that uses the table
+var tableFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:        "profile",
+		Usage:       "profile of the envi config files to use",
+		EnvVar:      "ENVI_PROFILE",
+		Destination: &profileName,
+	},
+	cli.StringFlag{
+		Name:        "table, t",
+		Value:       "envi",
+		Usage:       "name of the dynamodb to store values",
+		EnvVar:      "ENVI_TABLE",
+		Destination: &tableName,
+	},
+	cli.StringFlag{
+		Name:        "region, r",
+		Value:       "us-east-1",
+		Usage:       "name of the aws region in which dynamodb table resides",
+		EnvVar:      "ENVI_REGION",
+		Destination: &awsRegion,
+	},
+	cli.StringFlag{
+		Name:        "endpoint",
+		Usage:       "url of the dynamodb endpoint, e.g. http://localhost:8000 for DynamoDB Local",
+		EnvVar:      "ENVI_ENDPOINT",
+		Destination: &awsOptions.Endpoint,
+	},
+	cli.StringFlag{
+		Name:        "aws-profile",
+		Usage:       "aws profile to use instead of the default one",
+		EnvVar:      "ENVI_AWS_PROFILE",
+		Destination: &awsOptions.Profile,
+	},
+	cli.StringFlag{
+		Name:        "role-arn",
+		Usage:       "arn of a role to assume",
+		EnvVar:      "ENVI_ROLE_ARN",
+		Destination: &awsOptions.RoleARN,
+	},
+	cli.StringFlag{
+		Name:        "external-id",
+		Usage:       "external id required to assume the role",
+		EnvVar:      "ENVI_EXTERNAL_ID",
+		Destination: &awsOptions.ExternalID,
+	},
+	cli.StringFlag{
+		Name:        "mfa-serial",
+		Usage:       "serial number or arn of the mfa device required to assume the role; the token code is read from stdin",
+		EnvVar:      "ENVI_MFA_SERIAL",
+		Destination: &awsOptions.MFASerial,
+	},
+	cli.StringFlag{
+		Name:        "audit-file",
+		Usage:       "file to append an audit event to for every change",
+		EnvVar:      "ENVI_AUDIT_FILE",
+		Destination: &audit.File,
+	},
+	cli.StringFlag{
+		Name:        "audit-table",
+		Usage:       "dynamodb table to store an audit event in for every change",
+		EnvVar:      "ENVI_AUDIT_TABLE",
+		Destination: &audit.Table,
+	},
+	cli.StringFlag{
+		Name:        "audit-actor",
+		Usage:       "who makes the changes in audit events; the aws caller identity by default",
+		EnvVar:      "ENVI_AUDIT_ACTOR",
+		Destination: &audit.Actor,
+	},
+	cli.StringFlag{
+		Name:        "audit-hash-key",
+		Usage:       "key of the HMACs of secrets in audit events, so changed secrets can be told apart (default: leave secrets out)",
+		EnvVar:      "ENVI_AUDIT_HASH_KEY",
+		Destination: &auditHashKey,
+	},
+	cli.StringFlag{
+		Name:        "audit-secret-patterns",
+		Usage:       "comma separated patterns matching the names of secrets left out of audit events besides those declared by schemas (default: the secret patterns of dump)",
+		EnvVar:      "ENVI_AUDIT_SECRET_PATTERNS",
+		Destination: &auditSecretPatterns,
+	},
+}
+
+// initStore sets up the store for the table given by the table flags
+func initStore() error {
+	if audit.File != "" && audit.Table != "" {
+		return usageErrorf("can't audit to both a file and a table")
+	}
+	if err := store.InitWithOptions(awsRegion, tableName, awsOptions); err != nil {
+		return err
+	}
+	store.SetAudit(auditOptions())
+	return nil
+}
+
+// auditOptions returns how changes are audited as set by the audit flags
+func auditOptions() store.Audit {
+	options := audit
+	options.HashKey = []byte(auditHashKey)
+	options.SecretPatterns = splitList(auditSecretPatterns)
+	return options
+}
+
+// idFlag selects the application configuration of the commands that
+// work on a single one
+var idFlag = cli.StringFlag{
+	Name:        "id, i",
+	Value:       "",
+	Usage:       "id of the application environment combo: <app>__<environment>, or <environment> if the profile has an app",
+	Destination: &id,
+}
+
+// tableCommand adds the table flags to a command that uses the table
+// and fills in the flags that aren't given from the selected profile
+func tableCommand(command cli.Command) cli.Command {
+	command.Flags = append(command.Flags, tableFlags...)
+	command.Before = applyProfile
+	return command
+}
+
+// configCommand is tableCommand for a command that works on a single
+// application configuration
+func configCommand(command cli.Command) cli.Command {
+	command = tableCommand(command)
+	command.Flags = append(command.Flags, idFlag)
+	return command
+}
+
 func main() {
-	var tableName, awsRegion, id, variables, filePath, output string
+	var variables, filePath, format, output string
+	var create, ignoreMissing bool
 	app := cli.NewApp()
 
 	app.Description = "A simple application configuration store cli backed by dynamodb"
@@ -20,45 +162,31 @@ func main() {
    envi get -i app__dev
    envi g -i app__dev -o json`
 
-	globalFlags := []cli.Flag{
-		cli.StringFlag{
-			Name:        "table, t",
-			Value:       "envi",
-			Usage:       "name of the dynamodb to store values",
-			EnvVar:      "ENVI_TABLE",
-			Destination: &tableName,
-		},
-		cli.StringFlag{
-			Name:        "region, r",
-			Value:       "us-east-1",
-			Usage:       "name of the aws region in which dynamodb table resides",
-			EnvVar:      "ENVI_REGION",
-			Destination: &awsRegion,
-		},
-		cli.StringFlag{
-			Name:        "id, i",
-			Value:       "",
-			Usage:       "id of the application environment combo: <app>__<environment>",
-			Destination: &id,
-		},
-	}
-
 	setCommand := cli.Command{
 		Name:    "set",
 		Aliases: []string{"s"},
 		Usage:   "save application configuraton in dynamodb",
 		Action: func(c *cli.Context) error {
 			if id == "" {
-				return fmt.Errorf("must provide id")
+				return usageErrorf("must provide id")
 			}
 
-			store.Init(awsRegion, tableName)
+			if err := initStore(); err != nil {
+				return err
+			}
+			if fileVars := c.StringSlice("file-var"); len(fileVars) > 0 {
+				vars, err := withFileVars(variables, filePath, format, fileVars)
+				if err != nil {
+					return err
+				}
+				return store.SaveItem(store.CreateItem(id, vars))
+			}
 			if filePath != "" {
-				return store.SaveFromFile(id, filePath)
+				return store.SaveFromFileWithFormat(id, filePath, format)
 			} else if variables != "" {
 				return store.Save(id, variables)
 			}
-			return fmt.Errorf("must provide variables or a path to a file containing variables")
+			return usageErrorf("must provide variables or a path to a file containing variables")
 		},
 		Flags: []cli.Flag{
 			cli.StringFlag{
@@ -70,12 +198,18 @@ func main() {
 			cli.StringFlag{
 				Name:        "file, f",
 				Value:       "",
-				Usage:       "path to a shell file that exports env vars",
+				Usage:       "path to a file containing env vars or - for stdin",
 				Destination: &filePath,
 			},
+			cli.StringFlag{
+				Name:        "format",
+				Value:       "",
+				Usage:       "format of the file: env, json, ecs, yaml or docker; detected if not provided",
+				Destination: &format,
+			},
 		},
 	}
-	setCommand.Flags = append(setCommand.Flags, globalFlags...)
+	setCommand.Flags = append(setCommand.Flags, fileVarFlags...)
 
 	updateCommand := cli.Command{
 		Name:    "update",
@@ -83,16 +217,25 @@ func main() {
 		Usage:   "update an applications configuration by inserting new vars and updating old vars if specified",
 		Action: func(c *cli.Context) error {
 			if id == "" {
-				return fmt.Errorf("must provide id")
+				return usageErrorf("must provide id")
 			}
 
-			store.Init(awsRegion, tableName)
+			if err := initStore(); err != nil {
+				return err
+			}
+			if fileVars := c.StringSlice("file-var"); len(fileVars) > 0 {
+				vars, err := withFileVars(variables, filePath, format, fileVars)
+				if err != nil {
+					return err
+				}
+				return store.UpdateItem(store.CreateItem(id, vars), create)
+			}
 			if filePath != "" {
-				return store.UpdateFromFile(id, filePath)
+				return store.UpdateFromFileWithOptions(id, filePath, store.UpdateOptions{Create: create, Format: format})
 			} else if variables != "" {
-				return store.Update(id, variables)
+				return store.UpdateWithOptions(id, variables, store.UpdateOptions{Create: create})
 			}
-			return fmt.Errorf("must provide variables or a path to a file containing variables")
+			return usageErrorf("must provide variables or a path to a file containing variables")
 		},
 		Flags: []cli.Flag{
 			cli.StringFlag{
@@ -104,12 +247,23 @@ func main() {
 			cli.StringFlag{
 				Name:        "file, f",
 				Value:       "",
-				Usage:       "path to a shell file that exports env vars",
+				Usage:       "path to a file containing env vars or - for stdin",
 				Destination: &filePath,
 			},
+			cli.StringFlag{
+				Name:        "format",
+				Value:       "",
+				Usage:       "format of the file: env, json, ecs, yaml or docker; detected if not provided",
+				Destination: &format,
+			},
+			cli.BoolFlag{
+				Name:        "create",
+				Usage:       "create the config if it doesn't exist",
+				Destination: &create,
+			},
 		},
 	}
-	updateCommand.Flags = append(updateCommand.Flags, globalFlags...)
+	updateCommand.Flags = append(updateCommand.Flags, fileVarFlags...)
 
 	getCommand := cli.Command{
 		Name:    "get",
@@ -117,14 +271,19 @@ func main() {
 		Usage:   "get the application configuration for a particular application",
 		Action: func(c *cli.Context) error {
 			if id == "" {
-				return fmt.Errorf("must provide id")
+				return usageErrorf("must provide id")
 			}
 
-			store.Init(awsRegion, tableName)
+			if err := initStore(); err != nil {
+				return err
+			}
 			item, err := store.Get(id)
 			if err != nil {
 				return err
 			}
+			if !c.IsSet("output") && profile.Output != "" {
+				output = profile.Output
+			}
 			item.PrintVars(output)
 			return nil
 		},
@@ -137,7 +296,6 @@ func main() {
 			},
 		},
 	}
-	getCommand.Flags = append(getCommand.Flags, globalFlags...)
 
 	deleteCommand := cli.Command{
 		Name:    "delete",
@@ -145,16 +303,23 @@ func main() {
 		Usage:   "delete the application configuration for a particular application",
 		Action: func(c *cli.Context) error {
 			if id == "" {
-				return fmt.Errorf("Must provide id")
+				return usageErrorf("must provide id")
 			}
-			store.Init(awsRegion, tableName)
+			if err := initStore(); err != nil {
+				return err
+			}
+			var err error
 			if filePath != "" {
-				return store.DeleteVarsFromFile(id, filePath)
+				err = store.DeleteVarsFromFileWithFormat(id, filePath, format)
 			} else if variables != "" {
-				return store.DeleteVars(id, variables)
+				err = store.DeleteVars(id, variables)
 			} else {
-				return store.Delete(id)
+				err = store.Delete(id)
 			}
+			if ignoreMissing && errors.Is(err, store.ErrNotFound) {
+				return nil
+			}
+			return err
 		},
 		Flags: []cli.Flag{
 			cli.StringFlag{
@@ -166,22 +331,78 @@ func main() {
 			cli.StringFlag{
 				Name:        "file, f",
 				Value:       "",
-				Usage:       "path to a shell file that contains env vars",
+				Usage:       "path to a file containing env vars or - for stdin",
 				Destination: &filePath,
 			},
+			cli.StringFlag{
+				Name:        "format",
+				Value:       "",
+				Usage:       "format of the file: env, json, ecs, yaml or docker; detected if not provided",
+				Destination: &format,
+			},
+			cli.BoolFlag{
+				Name:        "ignore-missing",
+				Usage:       "don't fail if the config doesn't exist",
+				Destination: &ignoreMissing,
+			},
 		},
 	}
-	deleteCommand.Flags = append(deleteCommand.Flags, globalFlags...)
 
 	app.Commands = []cli.Command{
-		setCommand,
-		getCommand,
-		updateCommand,
-		deleteCommand,
+		configCommand(setCommand),
+		configCommand(getCommand),
+		configCommand(updateCommand),
+		configCommand(deleteCommand),
+		configCommand(renderECSCommand()),
+		configCommand(renderCommand()),
+		tableCommand(exportCommand()),
+		tableCommand(importCommand()),
+		tableCommand(copyCommand()),
+		configCommand(renameVarCommand()),
+		configCommand(renameCommand()),
+		tableCommand(applyCommand()),
+		tableCommand(syncCommand()),
+		tableCommand(dumpCommand()),
+		configCommand(checkCommand()),
+		tableCommand(serveCommand()),
+		schemaCommand(),
+		configCommand(validateCommand()),
+		configCommand(statCommand()),
+		configCommand(runCommand()),
+		tableCommand(initTableCommand()),
+		tableCommand(doctorCommand()),
+		configCommand(auditCommand()),
+	}
+
+	app.Flags = []cli.Flag{
+		cli.StringFlag{
+			Name:        "error-format",
+			Value:       "text",
+			Usage:       "format of errors written to stderr: text or json",
+			EnvVar:      "ENVI_ERROR_FORMAT",
+			Destination: &errorFormat,
+		},
+	}
+	app.Flags = append(app.Flags, lintFlags...)
+	app.Before = func(c *cli.Context) error {
+		if errorFormat != "text" && errorFormat != "json" {
+			return usageErrorf("unknown error format %s", errorFormat)
+		}
+		store.SetLintRules(lintRules())
+		return nil
+	}
+	app.Action = func(c *cli.Context) error {
+		if c.Args().Present() {
+			return usageErrorf("unknown command %s", c.Args().First())
+		}
+		return cli.ShowAppHelp(c)
+	}
+	app.OnUsageError = onUsageError
+	for i := range app.Commands {
+		app.Commands[i].OnUsageError = onUsageError
 	}
 
-	err := app.Run(os.Args)
-	if err != nil {
-		fmt.Println(err)
+	if err := app.Run(os.Args); err != nil {
+		os.Exit(writeError(os.Stderr, err))
 	}
 }
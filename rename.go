@@ -0,0 +1,58 @@
+package main
+
+import (
+	"github.com/tskinn/envi/store"
+	"github.com/urfave/cli"
+)
+
+func renameVarCommand() cli.Command {
+	command := cli.Command{
+		Name:      "rename-var",
+		Usage:     "rename a variable of an application configuration",
+		ArgsUsage: "<old name> <new name>",
+		Action: func(c *cli.Context) error {
+			if id == "" {
+				return usageErrorf("must provide id")
+			}
+			if c.NArg() != 2 {
+				return usageErrorf("must provide the old and new names of the variable")
+			}
+
+			if err := initStore(); err != nil {
+				return err
+			}
+			return store.RenameVar(id, c.Args().Get(0), c.Args().Get(1))
+		},
+	}
+	return command
+}
+
+func renameCommand() cli.Command {
+	var to string
+	command := cli.Command{
+		Name:  "rename",
+		Usage: "rename an application configuration",
+		Action: func(c *cli.Context) error {
+			if id == "" {
+				return usageErrorf("must provide id")
+			}
+			if to == "" {
+				return usageErrorf("must provide the new id")
+			}
+
+			if err := initStore(); err != nil {
+				return err
+			}
+			return store.Rename(id, profileID(to))
+		},
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:        "to",
+				Value:       "",
+				Usage:       "new id of the application configuration; prefixed like --id",
+				Destination: &to,
+			},
+		},
+	}
+	return command
+}
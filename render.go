@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/tskinn/envi/store"
+	"github.com/urfave/cli"
+)
+
+func renderCommand() cli.Command {
+	var templatePath, outPath, filesDir string
+	command := cli.Command{
+		Name:  "render",
+		Usage: "render a go text/template with the application configuration",
+		Action: func(c *cli.Context) error {
+			if id == "" {
+				return usageErrorf("must provide id")
+			}
+			if templatePath == "" {
+				return usageErrorf("must provide a path to a template")
+			}
+
+			text, err := ioutil.ReadFile(templatePath)
+			if err != nil {
+				return err
+			}
+			if err := initStore(); err != nil {
+				return err
+			}
+			item, err := store.Get(id)
+			if err != nil {
+				return err
+			}
+			if hasFileVars(item) {
+				// the files are kept since the rendered output refers
+				// to them
+				dir := filepath.Join(filesDir, id)
+				if filesDir == "" {
+					if dir, err = ioutil.TempDir("", "envi-"); err != nil {
+						return err
+					}
+				}
+				vars, err := item.Materialize(dir)
+				if err != nil {
+					return err
+				}
+				item = store.CreateItem(id, vars)
+			}
+			// render to a buffer first so a failed render doesn't
+			// leave a half written file behind
+			var buffer bytes.Buffer
+			err = item.Render(&buffer, filepath.Base(templatePath), string(text))
+			if err != nil {
+				return err
+			}
+			if outPath == "" {
+				_, err = os.Stdout.Write(buffer.Bytes())
+				return err
+			}
+			return store.WritePrivateFile(outPath, buffer.Bytes())
+		},
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:        "template",
+				Value:       "",
+				Usage:       "path to the go text/template file",
+				Destination: &templatePath,
+			},
+			cli.StringFlag{
+				Name:        "out",
+				Value:       "",
+				Usage:       "path to write the rendered template to; defaults to stdout",
+				Destination: &outPath,
+			},
+			cli.StringFlag{
+				Name:        "files-dir",
+				Value:       "",
+				Usage:       "directory in which the files of file variables are written, under a directory named after the id, e.g. /run/envi; defaults to a new directory in the system temporary directory",
+				EnvVar:      "ENVI_FILES_DIR",
+				Destination: &filesDir,
+			},
+		},
+	}
+	return command
+}
@@ -0,0 +1,348 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.4.0
+// - protoc             v4.25.3
+// source: envi.proto
+
+package envipb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.62.0 or later.
+const _ = grpc.SupportPackageIsVersion8
+
+const (
+	Envi_Get_FullMethodName    = "/envi.v1.Envi/Get"
+	Envi_Set_FullMethodName    = "/envi.v1.Envi/Set"
+	Envi_Update_FullMethodName = "/envi.v1.Envi/Update"
+	Envi_Delete_FullMethodName = "/envi.v1.Envi/Delete"
+	Envi_List_FullMethodName   = "/envi.v1.Envi/List"
+	Envi_Watch_FullMethodName  = "/envi.v1.Envi/Watch"
+)
+
+// EnviClient is the client API for Envi service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Envi serves application configurations from the store
+type EnviClient interface {
+	// Get gets the configuration with an id
+	Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*Item, error)
+	// Set replaces all of the variables of a configuration
+	Set(ctx context.Context, in *SetRequest, opts ...grpc.CallOption) (*Item, error)
+	// Update inserts new variables and updates existing variables of a
+	// configuration, leaving other variables untouched
+	Update(ctx context.Context, in *UpdateRequest, opts ...grpc.CallOption) (*Item, error)
+	// Delete deletes a configuration or only some of its variables
+	Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error)
+	// List lists the configurations whose ids start with a prefix
+	List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResponse, error)
+	// Watch sends the configuration with an id when the stream starts and
+	// again every time it changes
+	Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (Envi_WatchClient, error)
+}
+
+type enviClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewEnviClient(cc grpc.ClientConnInterface) EnviClient {
+	return &enviClient{cc}
+}
+
+func (c *enviClient) Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*Item, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Item)
+	err := c.cc.Invoke(ctx, Envi_Get_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *enviClient) Set(ctx context.Context, in *SetRequest, opts ...grpc.CallOption) (*Item, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Item)
+	err := c.cc.Invoke(ctx, Envi_Set_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *enviClient) Update(ctx context.Context, in *UpdateRequest, opts ...grpc.CallOption) (*Item, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Item)
+	err := c.cc.Invoke(ctx, Envi_Update_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *enviClient) Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteResponse)
+	err := c.cc.Invoke(ctx, Envi_Delete_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *enviClient) List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListResponse)
+	err := c.cc.Invoke(ctx, Envi_List_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *enviClient) Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (Envi_WatchClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Envi_ServiceDesc.Streams[0], Envi_Watch_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &enviWatchClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Envi_WatchClient interface {
+	Recv() (*Item, error)
+	grpc.ClientStream
+}
+
+type enviWatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *enviWatchClient) Recv() (*Item, error) {
+	m := new(Item)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// EnviServer is the server API for Envi service.
+// All implementations must embed UnimplementedEnviServer
+// for forward compatibility
+//
+// Envi serves application configurations from the store
+type EnviServer interface {
+	// Get gets the configuration with an id
+	Get(context.Context, *GetRequest) (*Item, error)
+	// Set replaces all of the variables of a configuration
+	Set(context.Context, *SetRequest) (*Item, error)
+	// Update inserts new variables and updates existing variables of a
+	// configuration, leaving other variables untouched
+	Update(context.Context, *UpdateRequest) (*Item, error)
+	// Delete deletes a configuration or only some of its variables
+	Delete(context.Context, *DeleteRequest) (*DeleteResponse, error)
+	// List lists the configurations whose ids start with a prefix
+	List(context.Context, *ListRequest) (*ListResponse, error)
+	// Watch sends the configuration with an id when the stream starts and
+	// again every time it changes
+	Watch(*WatchRequest, Envi_WatchServer) error
+	mustEmbedUnimplementedEnviServer()
+}
+
+// UnimplementedEnviServer must be embedded to have forward compatible implementations.
+type UnimplementedEnviServer struct {
+}
+
+func (UnimplementedEnviServer) Get(context.Context, *GetRequest) (*Item, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
+}
+func (UnimplementedEnviServer) Set(context.Context, *SetRequest) (*Item, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Set not implemented")
+}
+func (UnimplementedEnviServer) Update(context.Context, *UpdateRequest) (*Item, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Update not implemented")
+}
+func (UnimplementedEnviServer) Delete(context.Context, *DeleteRequest) (*DeleteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Delete not implemented")
+}
+func (UnimplementedEnviServer) List(context.Context, *ListRequest) (*ListResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method List not implemented")
+}
+func (UnimplementedEnviServer) Watch(*WatchRequest, Envi_WatchServer) error {
+	return status.Errorf(codes.Unimplemented, "method Watch not implemented")
+}
+func (UnimplementedEnviServer) mustEmbedUnimplementedEnviServer() {}
+
+// UnsafeEnviServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to EnviServer will
+// result in compilation errors.
+type UnsafeEnviServer interface {
+	mustEmbedUnimplementedEnviServer()
+}
+
+func RegisterEnviServer(s grpc.ServiceRegistrar, srv EnviServer) {
+	s.RegisterService(&Envi_ServiceDesc, srv)
+}
+
+func _Envi_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EnviServer).Get(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Envi_Get_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EnviServer).Get(ctx, req.(*GetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Envi_Set_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EnviServer).Set(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Envi_Set_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EnviServer).Set(ctx, req.(*SetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Envi_Update_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EnviServer).Update(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Envi_Update_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EnviServer).Update(ctx, req.(*UpdateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Envi_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EnviServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Envi_Delete_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EnviServer).Delete(ctx, req.(*DeleteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Envi_List_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EnviServer).List(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Envi_List_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EnviServer).List(ctx, req.(*ListRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Envi_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(EnviServer).Watch(m, &enviWatchServer{ServerStream: stream})
+}
+
+type Envi_WatchServer interface {
+	Send(*Item) error
+	grpc.ServerStream
+}
+
+type enviWatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *enviWatchServer) Send(m *Item) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// Envi_ServiceDesc is the grpc.ServiceDesc for Envi service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Envi_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "envi.v1.Envi",
+	HandlerType: (*EnviServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Get",
+			Handler:    _Envi_Get_Handler,
+		},
+		{
+			MethodName: "Set",
+			Handler:    _Envi_Set_Handler,
+		},
+		{
+			MethodName: "Update",
+			Handler:    _Envi_Update_Handler,
+		},
+		{
+			MethodName: "Delete",
+			Handler:    _Envi_Delete_Handler,
+		},
+		{
+			MethodName: "List",
+			Handler:    _Envi_List_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Watch",
+			Handler:       _Envi_Watch_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "envi.proto",
+}
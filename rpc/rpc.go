@@ -0,0 +1,370 @@
+// Package rpc serves application configurations from the store over
+// grpc. The service is defined in envipb/envi.proto.
+package rpc
+
+//go:generate protoc -I envipb --go_out=envipb --go_opt=paths=source_relative --go-grpc_out=envipb --go-grpc_opt=paths=source_relative envipb/envi.proto
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tskinn/envi/rpc/envipb"
+	"github.com/tskinn/envi/server"
+	"github.com/tskinn/envi/store"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultPollInterval is how often watched configs are read from the
+// store to notice changes made outside of the server
+const DefaultPollInterval = 10 * time.Second
+
+// Options configure the server. Authentication and authorization work
+// the same as for the REST api of the server package.
+type Options struct {
+	// Client is the store the configs are served from
+	Client *store.Client
+	// Authenticator authenticates the bearer token in the authorization
+	// metadata of every call. Calls aren't authenticated if it is nil.
+	Authenticator server.Authenticator
+	// Policy authorizes the calls of authenticated principals. If it is nil
+	// every call is allowed but secrets are always masked.
+	Policy *server.Policy
+	// SecretPatterns match the names of variables that are only returned
+	// to principals allowed to read-secrets
+	SecretPatterns []string
+	// PollInterval is how often watched configs are read from the store.
+	// DefaultPollInterval is used if zero.
+	PollInterval time.Duration
+}
+
+// Server implements envipb.EnviServer on top of the store
+type Server struct {
+	envipb.UnimplementedEnviServer
+	options Options
+
+	mu       sync.Mutex
+	watchers map[string]map[chan struct{}]bool
+}
+
+type principalKey struct{}
+
+// NewServer returns a grpc server serving the Envi service
+func NewServer(options Options) *grpc.Server {
+	if len(options.SecretPatterns) == 0 {
+		options.SecretPatterns = store.DefaultSecretPatterns
+	}
+	if options.PollInterval == 0 {
+		options.PollInterval = DefaultPollInterval
+	}
+	s := &Server{
+		options:  options,
+		watchers: make(map[string]map[chan struct{}]bool),
+	}
+	grpcServer := grpc.NewServer(
+		grpc.UnaryInterceptor(s.authenticateUnary),
+		grpc.StreamInterceptor(s.authenticateStream),
+	)
+	envipb.RegisterEnviServer(grpcServer, s)
+	return grpcServer
+}
+
+// authenticate returns a context holding the principal of the bearer
+// token in the authorization metadata
+func (s *Server) authenticate(ctx context.Context) (context.Context, error) {
+	if s.options.Authenticator == nil {
+		return ctx, nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 || !strings.HasPrefix(values[0], "Bearer ") {
+		return nil, status.Error(codes.Unauthenticated, "missing bearer token")
+	}
+	principal, err := s.options.Authenticator.Authenticate(strings.TrimPrefix(values[0], "Bearer "))
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	return store.WithActor(context.WithValue(ctx, principalKey{}, principal), principal), nil
+}
+
+func (s *Server) authenticateUnary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (stream *authenticatedStream) Context() context.Context {
+	return stream.ctx
+}
+
+func (s *Server) authenticateStream(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := s.authenticate(stream.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authenticatedStream{ServerStream: stream, ctx: ctx})
+}
+
+func (s *Server) allowed(ctx context.Context, id, action string) bool {
+	if s.options.Policy == nil {
+		return action != server.ActionReadSecrets
+	}
+	principal, _ := ctx.Value(principalKey{}).(string)
+	return s.options.Policy.Allowed(principal, id, action)
+}
+
+func (s *Server) authorize(ctx context.Context, id, action string) error {
+	if id == "" {
+		return status.Error(codes.InvalidArgument, "must provide id")
+	}
+	if !s.allowed(ctx, id, action) {
+		return status.Errorf(codes.PermissionDenied, "not allowed to %s %s", action, id)
+	}
+	return nil
+}
+
+// toProto converts an item to its protobuf message masking secrets
+// unless the caller may read them. Secrets are the variables matching the
+// secret patterns and those declared secret by the schema of the
+// application, which are cached by application in schemaSecrets.
+func (s *Server) toProto(ctx context.Context, item store.Item, schemaSecrets map[string][]string) (*envipb.Item, error) {
+	if !s.allowed(ctx, item.ID, server.ActionReadSecrets) {
+		app := store.AppFromID(item.ID)
+		secrets, cached := schemaSecrets[app]
+		if !cached {
+			var err error
+			if secrets, err = s.options.Client.SchemaSecrets(ctx, app); err != nil {
+				return nil, storeError(err)
+			}
+			schemaSecrets[app] = secrets
+		}
+		item = item.MaskSecrets(s.options.SecretPatterns, secrets...)
+	}
+	message := &envipb.Item{Id: item.ID, Variables: make([]*envipb.Variable, len(item.Variables))}
+	for i, variable := range item.Variables {
+		message.Variables[i] = &envipb.Variable{Name: variable.Name, Value: variable.Value, ContentType: variable.ContentType}
+	}
+	return message, nil
+}
+
+func fromProto(message *envipb.Item) (store.Item, error) {
+	if message == nil || message.Id == "" {
+		return store.Item{}, status.Error(codes.InvalidArgument, "must provide an item with an id")
+	}
+	item := store.Item{ID: message.Id, Variables: make([]store.Variable, len(message.Variables))}
+	for i, variable := range message.Variables {
+		if variable.GetName() == "" {
+			return item, status.Error(codes.InvalidArgument, "variables must have a name")
+		}
+		item.Variables[i] = store.Variable{Name: variable.Name, Value: variable.Value, ContentType: variable.ContentType}
+	}
+	return item, nil
+}
+
+// storeError converts an error of the store to a grpc status
+func storeError(err error) error {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, store.ErrConflict):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, store.ErrValidation):
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// written gets the item after a write for the caller, or only its id if
+// the caller may not read it
+func (s *Server) written(ctx context.Context, id string) (*envipb.Item, error) {
+	if !s.allowed(ctx, id, server.ActionRead) {
+		return &envipb.Item{Id: id}, nil
+	}
+	return s.get(ctx, id)
+}
+
+// get gets the item from the store and converts it for the caller
+func (s *Server) get(ctx context.Context, id string) (*envipb.Item, error) {
+	item, err := s.options.Client.Get(ctx, id)
+	if err != nil {
+		return nil, storeError(err)
+	}
+	item.ID = id
+	return s.toProto(ctx, item, make(map[string][]string))
+}
+
+// Get gets the configuration with an id
+func (s *Server) Get(ctx context.Context, req *envipb.GetRequest) (*envipb.Item, error) {
+	if err := s.authorize(ctx, req.Id, server.ActionRead); err != nil {
+		return nil, err
+	}
+	return s.get(ctx, req.Id)
+}
+
+// Set replaces all of the variables of a configuration
+func (s *Server) Set(ctx context.Context, req *envipb.SetRequest) (*envipb.Item, error) {
+	item, err := fromProto(req.Item)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.authorize(ctx, item.ID, server.ActionWrite); err != nil {
+		return nil, err
+	}
+	if err := s.options.Client.Save(ctx, item); err != nil {
+		return nil, storeError(err)
+	}
+	s.notify(item.ID)
+	return s.written(ctx, item.ID)
+}
+
+// Update inserts new variables and updates existing variables of a
+// configuration
+func (s *Server) Update(ctx context.Context, req *envipb.UpdateRequest) (*envipb.Item, error) {
+	item, err := fromProto(req.Item)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.authorize(ctx, item.ID, server.ActionWrite); err != nil {
+		return nil, err
+	}
+	if err := s.options.Client.Update(ctx, item, req.Create); err != nil {
+		return nil, storeError(err)
+	}
+	s.notify(item.ID)
+	return s.written(ctx, item.ID)
+}
+
+// Delete deletes a configuration or only some of its variables
+func (s *Server) Delete(ctx context.Context, req *envipb.DeleteRequest) (*envipb.DeleteResponse, error) {
+	if err := s.authorize(ctx, req.Id, server.ActionWrite); err != nil {
+		return nil, err
+	}
+	var err error
+	if len(req.Variables) > 0 {
+		err = s.options.Client.DeleteVars(ctx, req.Id, req.Variables)
+	} else {
+		err = s.options.Client.Delete(ctx, req.Id)
+	}
+	if err != nil {
+		return nil, storeError(err)
+	}
+	s.notify(req.Id)
+	return &envipb.DeleteResponse{}, nil
+}
+
+// List lists the configurations the caller may read whose ids start with
+// a prefix
+func (s *Server) List(ctx context.Context, req *envipb.ListRequest) (*envipb.ListResponse, error) {
+	items, err := s.options.Client.List(ctx, req.Prefix)
+	if err != nil {
+		return nil, storeError(err)
+	}
+	response := &envipb.ListResponse{Items: make([]*envipb.Item, 0, len(items))}
+	schemaSecrets := make(map[string][]string)
+	for _, item := range items {
+		if !s.allowed(ctx, item.ID, server.ActionRead) {
+			continue
+		}
+		message, err := s.toProto(ctx, item, schemaSecrets)
+		if err != nil {
+			return nil, err
+		}
+		response.Items = append(response.Items, message)
+	}
+	return response, nil
+}
+
+// Watch sends the configuration with an id when the stream starts and
+// again every time it changes. Changes made through this server are sent
+// right away and changes made elsewhere when the store is next polled.
+func (s *Server) Watch(req *envipb.WatchRequest, stream envipb.Envi_WatchServer) error {
+	ctx := stream.Context()
+	if err := s.authorize(ctx, req.Id, server.ActionRead); err != nil {
+		return err
+	}
+	changed := s.subscribe(req.Id)
+	defer s.unsubscribe(req.Id, changed)
+	ticker := time.NewTicker(s.options.PollInterval)
+	defer ticker.Stop()
+
+	var last *envipb.Item
+	for {
+		item, err := s.get(ctx, req.Id)
+		// a missing config is watched until it is created
+		if status.Code(err) == codes.NotFound {
+			item, err = &envipb.Item{Id: req.Id}, nil
+		}
+		if err != nil {
+			return err
+		}
+		if last == nil || !itemsEqual(last, item) {
+			if err := stream.Send(item); err != nil {
+				return err
+			}
+			last = item
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-changed:
+		case <-ticker.C:
+		}
+	}
+}
+
+func itemsEqual(one, two *envipb.Item) bool {
+	if len(one.Variables) != len(two.Variables) {
+		return false
+	}
+	for i := range one.Variables {
+		if one.Variables[i].Name != two.Variables[i].Name || one.Variables[i].Value != two.Variables[i].Value ||
+			one.Variables[i].ContentType != two.Variables[i].ContentType {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Server) subscribe(id string) chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	changed := make(chan struct{}, 1)
+	if s.watchers[id] == nil {
+		s.watchers[id] = make(map[chan struct{}]bool)
+	}
+	s.watchers[id][changed] = true
+	return changed
+}
+
+func (s *Server) unsubscribe(id string, changed chan struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.watchers[id], changed)
+	if len(s.watchers[id]) == 0 {
+		delete(s.watchers, id)
+	}
+}
+
+// notify wakes up the watchers of id
+func (s *Server) notify(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for changed := range s.watchers[id] {
+		select {
+		case changed <- struct{}{}:
+		default: // already woken up
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tskinn/envi/internal/storetest"
+	"github.com/tskinn/envi/rpc/envipb"
+	"github.com/tskinn/envi/server"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newTestClient serves options over an in memory connection. A store
+// client is created if options has none.
+func newTestClient(t *testing.T, options Options) (envipb.EnviClient, func()) {
+	if options.Client == nil {
+		options.Client, _ = storetest.NewClient(t)
+	}
+	listener := bufconn.Listen(1024 * 1024)
+	grpcServer := NewServer(options)
+	go grpcServer.Serve(listener)
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("error dialing server %s", err)
+	}
+	return envipb.NewEnviClient(conn), func() {
+		conn.Close()
+		grpcServer.Stop()
+	}
+}
+
+func TestSetUpdateGetDelete(t *testing.T) {
+	client, stop := newTestClient(t, Options{})
+	defer stop()
+	ctx := context.Background()
+
+	item, err := client.Set(ctx, &envipb.SetRequest{Item: &envipb.Item{
+		Id:        "app__prod",
+		Variables: []*envipb.Variable{{Name: "one", Value: "two"}, {Name: "DB_PASSWORD", Value: "hunter2"}},
+	}})
+	if err != nil {
+		t.Fatalf("error setting %s", err)
+	}
+	if len(item.Variables) != 2 || item.Variables[0].Value != "two" || item.Variables[1].Value == "hunter2" {
+		t.Fatalf("expected set response with the secret masked without a policy %v", item)
+	}
+	_, err = client.Update(ctx, &envipb.UpdateRequest{Item: &envipb.Item{
+		Id:        "app__prod",
+		Variables: []*envipb.Variable{{Name: "one", Value: "ten"}},
+	}})
+	if err != nil {
+		t.Fatalf("error updating %s", err)
+	}
+	item, err = client.Get(ctx, &envipb.GetRequest{Id: "app__prod"})
+	if err != nil {
+		t.Fatalf("error getting %s", err)
+	}
+	if item.Variables[0].Value != "ten" {
+		t.Fatalf("unexpected get response %v", item)
+	}
+	list, err := client.List(ctx, &envipb.ListRequest{Prefix: "app__"})
+	if err != nil || len(list.Items) != 1 {
+		t.Fatalf("unexpected list response %v %v", list, err)
+	}
+	if _, err := client.Delete(ctx, &envipb.DeleteRequest{Id: "app__prod", Variables: []string{"one"}}); err != nil {
+		t.Fatalf("error deleting %s", err)
+	}
+	item, err = client.Get(ctx, &envipb.GetRequest{Id: "app__prod"})
+	if err != nil || len(item.Variables) != 1 {
+		t.Fatalf("expected variable to be deleted %v %v", item, err)
+	}
+	if _, err := client.Set(ctx, &envipb.SetRequest{}); err == nil {
+		t.Fatalf("expected error setting without an item")
+	}
+	if _, err := client.Get(ctx, &envipb.GetRequest{Id: "app__missing"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected not found getting a missing config, got %v", err)
+	}
+	missing := &envipb.Item{Id: "app__missing", Variables: []*envipb.Variable{{Name: "one", Value: "two"}}}
+	if _, err := client.Update(ctx, &envipb.UpdateRequest{Item: missing}); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected not found updating a missing config, got %v", err)
+	}
+	if _, err := client.Update(ctx, &envipb.UpdateRequest{Item: missing, Create: true}); err != nil {
+		t.Fatalf("error updating with create %s", err)
+	}
+}
+
+func TestWriteOnly(t *testing.T) {
+	policy := &server.Policy{Rules: []server.Rule{{Principals: []string{"*"}, IDs: []string{"*"}, Actions: []string{server.ActionWrite}}}}
+	client, stop := newTestClient(t, Options{Policy: policy})
+	defer stop()
+	ctx := context.Background()
+
+	item, err := client.Set(ctx, &envipb.SetRequest{Item: &envipb.Item{
+		Id:        "app__prod",
+		Variables: []*envipb.Variable{{Name: "one", Value: "two"}},
+	}})
+	if err != nil {
+		t.Fatalf("error setting %s", err)
+	}
+	if item.Id != "app__prod" || len(item.Variables) != 0 {
+		t.Fatalf("expected only the id in the set response %v", item)
+	}
+	item, err = client.Update(ctx, &envipb.UpdateRequest{Item: &envipb.Item{
+		Id:        "app__prod",
+		Variables: []*envipb.Variable{{Name: "one", Value: "ten"}},
+	}})
+	if err != nil || len(item.Variables) != 0 {
+		t.Fatalf("expected only the id in the update response %v %v", item, err)
+	}
+	if _, err := client.Get(ctx, &envipb.GetRequest{Id: "app__prod"}); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected permission denied getting, got %v", err)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	storeClient, _ := storetest.NewClient(t)
+	client, stop := newTestClient(t, Options{Client: storeClient, PollInterval: time.Hour})
+	defer stop()
+	storetest.SaveItem(t, storeClient, "app__prod", "one=two")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := client.Watch(ctx, &envipb.WatchRequest{Id: "app__prod"})
+	if err != nil {
+		t.Fatalf("error watching %s", err)
+	}
+	item, err := stream.Recv()
+	if err != nil || item.Variables[0].Value != "two" {
+		t.Fatalf("expected current item first %v %v", item, err)
+	}
+	_, err = client.Update(ctx, &envipb.UpdateRequest{Item: &envipb.Item{
+		Id:        "app__prod",
+		Variables: []*envipb.Variable{{Name: "one", Value: "ten"}},
+	}})
+	if err != nil {
+		t.Fatalf("error updating %s", err)
+	}
+	item, err = stream.Recv()
+	if err != nil || item.Variables[0].Value != "ten" {
+		t.Fatalf("expected changed item %v %v", item, err)
+	}
+}
+
+func TestSchemaSecretsMasked(t *testing.T) {
+	storeClient, _ := storetest.NewClient(t)
+	ctx := context.Background()
+	if err := storeClient.SaveSchema(ctx, "billing", strings.NewReader("variables:\n  LICENSE:\n    secret: true\n")); err != nil {
+		t.Fatalf("error saving schema %s", err)
+	}
+	storetest.SaveItem(t, storeClient, "billing__prod", "DB_HOST=db", "LICENSE=abc123")
+	// everyone may read but not read secrets
+	policy := &server.Policy{Rules: []server.Rule{{Principals: []string{"*"}, IDs: []string{"*"}, Actions: []string{server.ActionRead}}}}
+	client, stop := newTestClient(t, Options{Client: storeClient, Policy: policy})
+	defer stop()
+
+	item, err := client.Get(ctx, &envipb.GetRequest{Id: "billing__prod"})
+	if err != nil {
+		t.Fatalf("error getting %s", err)
+	}
+	if item.Variables[0].Value != "db" || item.Variables[1].Value == "abc123" {
+		t.Fatalf("expected the secret of the schema to be masked %v", item)
+	}
+	list, err := client.List(ctx, &envipb.ListRequest{})
+	if err != nil || len(list.Items) != 1 || list.Items[0].Variables[1].Value == "abc123" {
+		t.Fatalf("expected the secret of the schema to be masked when listing %v %v", list, err)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+
+	"github.com/tskinn/envi/store"
+	"github.com/urfave/cli"
+)
+
+func runCommand() cli.Command {
+	var filesDir string
+	command := cli.Command{
+		Name:      "run",
+		Usage:     "run a command with the application configuration in its environment",
+		ArgsUsage: "-- command [arguments...]",
+		Action: func(c *cli.Context) error {
+			if id == "" {
+				return usageErrorf("must provide id")
+			}
+			if !c.Args().Present() {
+				return usageErrorf("must provide a command to run")
+			}
+
+			if err := initStore(); err != nil {
+				return err
+			}
+			item, err := store.Get(id)
+			if err != nil {
+				return err
+			}
+			vars := item.Variables
+			if hasFileVars(item) {
+				// the files only exist while the command runs
+				dir, err := ioutil.TempDir(filesDir, "envi-")
+				if err != nil {
+					return err
+				}
+				defer os.RemoveAll(dir)
+				if vars, err = item.Materialize(dir); err != nil {
+					return err
+				}
+			}
+
+			cmd := exec.Command(c.Args().First(), c.Args().Tail()...)
+			cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+			cmd.Env = os.Environ()
+			for _, variable := range vars {
+				cmd.Env = append(cmd.Env, variable.Name+"="+variable.Value)
+			}
+			if err := cmd.Start(); err != nil {
+				return err
+			}
+			// pass signals on to the command and wait for it to exit so
+			// the files are removed
+			signals := make(chan os.Signal, 1)
+			signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+			defer signal.Stop(signals)
+			go func() {
+				for sig := range signals {
+					cmd.Process.Signal(sig)
+				}
+			}()
+			err = cmd.Wait()
+			if exitErr, ok := err.(*exec.ExitError); ok {
+				code := exitErr.ExitCode()
+				// killed by a signal
+				if code < 0 {
+					code = exitError
+				}
+				return &commandExit{code: code}
+			}
+			return err
+		},
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:        "files-dir",
+				Value:       "",
+				Usage:       "directory in which the files of file variables are written, e.g. /run/envi; defaults to the system temporary directory",
+				EnvVar:      "ENVI_FILES_DIR",
+				Destination: &filesDir,
+			},
+		},
+	}
+	return command
+}
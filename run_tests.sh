@@ -2,10 +2,13 @@
 
 set -e
 
-cd store
-go test
+go test ./...
 printf "\ngo tests passed!\n\n"
-cd ..
+
+# a built binary, unlike go run, exits with the exit code of envi
+ENVI=$(mktemp)
+trap 'rm -f ${ENVI}' EXIT
+go build -o ${ENVI} .
 
 set +e
 
@@ -18,14 +21,14 @@ ID="supertest__somegarbageid"
 
 # test setting
 echo "Testing 'set' command..."
-if ! go run *.go s -i ${ID} -e one=two,three=four; then
+if ! ${ENVI} s -i ${ID} -e one=two,three=four; then
 		fail
 fi
 printf "\t'set' succesfully tested.\n"
 
 # test getting
 echo "Testing 'get' command..."
-results=$(go run *.go g -i ${ID})
+results=$(${ENVI} g -i ${ID})
 success=$?
 if [ ${success} -ne 0 ]; then
 		fail
@@ -41,10 +44,10 @@ printf "\t'get' successfully tested.\n"
 
 # test updating
 echo "Testing 'update' command..."
-if ! go run *.go u -i ${ID} -e one=one; then
+if ! ${ENVI} u -i ${ID} -e one=one; then
 		fail
 fi
-results=$(go run *.go g -i ${ID})
+results=$(${ENVI} g -i ${ID})
 if ! echo "${results}" | grep -q "one=one"; then
 		fail
 fi
@@ -56,8 +59,8 @@ printf "\t'update' succesfully tested.\n"
 
 # test deleting variable
 echo "Testing 'delete' variable command..."
-go run *.go d -i ${ID} -e one
-results=$(go run *.go g -i ${ID})
+${ENVI} d -i ${ID} -e one
+results=$(${ENVI} g -i ${ID})
 success=$?
 if [ ${success} -ne 0 ]; then
 		fail
@@ -72,17 +75,13 @@ printf "\t'delete' variable succesfully tested.\n"
 
 # test deleting config
 echo "Testing 'delete' command..."
-if ! go run *.go d -i ${ID}; then
+if ! ${ENVI} d -i ${ID}; then
 		fail
 fi
-results=$(go run *.go g -i ${ID})
+# getting a deleted config exits with 3, not found
+${ENVI} g -i ${ID} 2>/dev/null
 success=$?
-if [ ${success} -ne 0 ]; then
-		fail
-fi
-
-lines=$(echo "${results}" | wc -l)
-if [ ${lines} -eq 0 ]; then
+if [ ${success} -ne 3 ]; then
 		fail
 fi
 printf "\t'delete' succesfully tested.\n"
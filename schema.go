@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/tskinn/envi/store"
+	"github.com/urfave/cli"
+)
+
+func schemaCommand() cli.Command {
+	var app, filePath string
+	appFlag := cli.StringFlag{
+		Name:        "app, a",
+		Value:       "",
+		Usage:       "name of the application, the part of its ids before __",
+		Destination: &app,
+	}
+
+	setCommand := cli.Command{
+		Name:  "set",
+		Usage: "register the schema of an application; set and update then validate its configs",
+		Action: func(c *cli.Context) error {
+			if app == "" {
+				return usageErrorf("must provide an application")
+			}
+			if filePath == "" {
+				return usageErrorf("must provide a path to a schema file")
+			}
+			var in io.Reader = os.Stdin
+			if filePath != "-" {
+				file, err := os.Open(filePath)
+				if err != nil {
+					return err
+				}
+				defer file.Close()
+				in = file
+			}
+			if err := initStore(); err != nil {
+				return err
+			}
+			return store.SaveSchema(app, in)
+		},
+		Flags: []cli.Flag{
+			appFlag,
+			cli.StringFlag{
+				Name:        "file, f",
+				Value:       "",
+				Usage:       "path to a yaml schema file or - for stdin",
+				Destination: &filePath,
+			},
+		},
+	}
+
+	getCommand := cli.Command{
+		Name:  "get",
+		Usage: "print the schema of an application",
+		Action: func(c *cli.Context) error {
+			if app == "" {
+				return usageErrorf("must provide an application")
+			}
+			if err := initStore(); err != nil {
+				return err
+			}
+			_, raw, err := store.GetSchema(app)
+			if err != nil {
+				return err
+			}
+			fmt.Print(raw)
+			return nil
+		},
+		Flags: []cli.Flag{appFlag},
+	}
+
+	return cli.Command{
+		Name:        "schema",
+		Usage:       "manage the schemas declaring the variables of an application's configs",
+		Subcommands: []cli.Command{tableCommand(setCommand), tableCommand(getCommand)},
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/tskinn/envi/rpc"
+	"github.com/tskinn/envi/server"
+	"github.com/tskinn/envi/store"
+	"github.com/urfave/cli"
+)
+
+func serveCommand() cli.Command {
+	var addr, grpcAddr, tokensFile, jwksFile, jwtIssuer, jwtAudience, policyFile, secretPatterns string
+	var pollInterval time.Duration
+	command := cli.Command{
+		Name:  "serve",
+		Usage: "serve application configurations over a REST api and/or grpc",
+		Action: func(c *cli.Context) error {
+			options := server.Options{SecretPatterns: splitList(secretPatterns)}
+			var authenticators server.Authenticators
+			if tokensFile != "" {
+				authenticator, err := server.LoadTokenAuthenticator(tokensFile)
+				if err != nil {
+					return err
+				}
+				authenticators = append(authenticators, authenticator)
+			}
+			if jwksFile != "" {
+				authenticator, err := server.LoadJWTAuthenticator(jwksFile)
+				if err != nil {
+					return err
+				}
+				authenticator.Issuer = jwtIssuer
+				authenticator.Audience = jwtAudience
+				authenticators = append(authenticators, authenticator)
+			}
+			if len(authenticators) > 0 {
+				options.Authenticator = authenticators
+			}
+			if policyFile != "" {
+				if options.Authenticator == nil {
+					return usageErrorf("a policy requires a tokens file or jwks file to authenticate principals")
+				}
+				policy, err := server.LoadPolicy(policyFile)
+				if err != nil {
+					return err
+				}
+				options.Policy = policy
+			}
+			if options.Authenticator == nil {
+				log.Printf("WARNING: serving without authentication")
+			}
+
+			if addr == "" && grpcAddr == "" {
+				return usageErrorf("must provide an address to serve the REST api or grpc on")
+			}
+
+			if audit.File != "" && audit.Table != "" {
+				return usageErrorf("can't audit to both a file and a table")
+			}
+			// the values the server masks are left out of audit events too
+			auditing := auditOptions()
+			auditing.SecretPatterns = append(auditing.SecretPatterns, options.SecretPatterns...)
+			client, err := store.NewClient(store.WithRegion(awsRegion), store.WithTable(tableName), store.WithAWSOptions(awsOptions), store.WithLintRules(lintRules()), store.WithAudit(auditing))
+			if err != nil {
+				return err
+			}
+			options.Client = client
+			errs := make(chan error, 2)
+			if addr != "" {
+				httpServer := &http.Server{
+					Addr:         addr,
+					Handler:      server.New(options),
+					ReadTimeout:  10 * time.Second,
+					WriteTimeout: 30 * time.Second,
+				}
+				log.Printf("serving %s over http on %s", tableName, addr)
+				go func() { errs <- httpServer.ListenAndServe() }()
+			}
+			if grpcAddr != "" {
+				listener, err := net.Listen("tcp", grpcAddr)
+				if err != nil {
+					return err
+				}
+				grpcServer := rpc.NewServer(rpc.Options{
+					Client:         client,
+					Authenticator:  options.Authenticator,
+					Policy:         options.Policy,
+					SecretPatterns: options.SecretPatterns,
+					PollInterval:   pollInterval,
+				})
+				log.Printf("serving %s over grpc on %s", tableName, grpcAddr)
+				go func() { errs <- grpcServer.Serve(listener) }()
+			}
+			return <-errs
+		},
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:        "addr",
+				Value:       "127.0.0.1:8080",
+				Usage:       "address to serve the REST api on, e.g. :8080 for every interface; empty to not serve it",
+				EnvVar:      "ENVI_ADDR",
+				Destination: &addr,
+			},
+			cli.StringFlag{
+				Name:        "grpc-addr",
+				Value:       "",
+				Usage:       "address to serve grpc on; empty to not serve it",
+				EnvVar:      "ENVI_GRPC_ADDR",
+				Destination: &grpcAddr,
+			},
+			cli.DurationFlag{
+				Name:        "poll-interval",
+				Value:       rpc.DefaultPollInterval,
+				Usage:       "how often configs watched over grpc are read to notice changes made elsewhere",
+				Destination: &pollInterval,
+			},
+			cli.StringFlag{
+				Name:        "tokens-file",
+				Value:       "",
+				Usage:       "path to a yaml file of static bearer tokens and their principals",
+				EnvVar:      "ENVI_TOKENS_FILE",
+				Destination: &tokensFile,
+			},
+			cli.StringFlag{
+				Name:        "jwks-file",
+				Value:       "",
+				Usage:       "path to a jwks file with the keys that sign accepted jwts",
+				EnvVar:      "ENVI_JWKS_FILE",
+				Destination: &jwksFile,
+			},
+			cli.StringFlag{
+				Name:        "jwt-issuer",
+				Value:       "",
+				Usage:       "issuer that accepted jwts must have",
+				EnvVar:      "ENVI_JWT_ISSUER",
+				Destination: &jwtIssuer,
+			},
+			cli.StringFlag{
+				Name:        "jwt-audience",
+				Value:       "",
+				Usage:       "audience that accepted jwts must have",
+				EnvVar:      "ENVI_JWT_AUDIENCE",
+				Destination: &jwtAudience,
+			},
+			cli.StringFlag{
+				Name:        "policy-file",
+				Value:       "",
+				Usage:       "path to a yaml file mapping principals to the actions allowed on ids",
+				EnvVar:      "ENVI_POLICY_FILE",
+				Destination: &policyFile,
+			},
+			cli.StringFlag{
+				Name:        "secret-patterns",
+				Value:       "",
+				Usage:       "comma separated patterns matching the names of secrets (default: same as dump)",
+				Destination: &secretPatterns,
+			},
+		},
+	}
+	return command
+}
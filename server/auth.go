@@ -0,0 +1,252 @@
+package server
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// Authenticator authenticates the bearer token of a request and returns
+// the principal it belongs to
+type Authenticator interface {
+	Authenticate(token string) (string, error)
+}
+
+// TokenAuthenticator authenticates static tokens
+type TokenAuthenticator struct {
+	tokens []staticToken
+}
+
+// staticToken is an entry of a tokens file. The token is either given as
+// is or as the hex encoded sha256 of it so the file doesn't have to hold
+// the token itself.
+type staticToken struct {
+	Principal string `yaml:"principal"`
+	Token     string `yaml:"token"`
+	SHA256    string `yaml:"sha256"`
+}
+
+// LoadTokenAuthenticator reads a yaml tokens file of the form
+//
+//	tokens:
+//	  - principal: ci-bot
+//	    token: some-long-random-token
+//	  - principal: dashboard
+//	    sha256: 9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08
+func LoadTokenAuthenticator(fileName string) (*TokenAuthenticator, error) {
+	content, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Tokens []staticToken `yaml:"tokens"`
+	}
+	if err := unmarshalStrict(content, &file); err != nil {
+		return nil, fmt.Errorf("error parsing tokens file: %s", err)
+	}
+	for i, token := range file.Tokens {
+		if token.Principal == "" || (token.Token == "") == (token.SHA256 == "") {
+			return nil, fmt.Errorf("token %d must have a principal and one of token or sha256", i)
+		}
+	}
+	return &TokenAuthenticator{tokens: file.Tokens}, nil
+}
+
+// Authenticate returns the principal of the token
+func (a *TokenAuthenticator) Authenticate(token string) (string, error) {
+	sum := sha256.Sum256([]byte(token))
+	hashed := hex.EncodeToString(sum[:])
+	for _, candidate := range a.tokens {
+		if candidate.Token != "" && subtle.ConstantTimeCompare([]byte(candidate.Token), []byte(token)) == 1 {
+			return candidate.Principal, nil
+		}
+		if candidate.SHA256 != "" && subtle.ConstantTimeCompare([]byte(strings.ToLower(candidate.SHA256)), []byte(hashed)) == 1 {
+			return candidate.Principal, nil
+		}
+	}
+	return "", fmt.Errorf("invalid token")
+}
+
+// JWTAuthenticator authenticates RSA signed JWTs against the keys of a
+// JWKS file. The principal is the subject of the token.
+type JWTAuthenticator struct {
+	keys map[string]*rsa.PublicKey
+	// Issuer, if set, must match the iss claim
+	Issuer string
+	// Audience, if set, must be in the aud claim
+	Audience string
+}
+
+// leeway allowed between clocks when checking exp and nbf
+const jwtLeeway = 30 * time.Second
+
+var jwtAlgorithms = map[string]crypto.Hash{
+	"RS256": crypto.SHA256,
+	"RS384": crypto.SHA384,
+	"RS512": crypto.SHA512,
+}
+
+type jwk struct {
+	KeyType string `json:"kty"`
+	KeyID   string `json:"kid"`
+	Use     string `json:"use"`
+	N       string `json:"n"`
+	E       string `json:"e"`
+}
+
+// LoadJWTAuthenticator reads the RSA keys of a JWKS file
+func LoadJWTAuthenticator(fileName string) (*JWTAuthenticator, error) {
+	content, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(content, &set); err != nil {
+		return nil, fmt.Errorf("error parsing jwks file: %s", err)
+	}
+	keys := make(map[string]*rsa.PublicKey)
+	for _, key := range set.Keys {
+		if key.KeyType != "RSA" || (key.Use != "" && key.Use != "sig") {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(key.N)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding key %s: %s", key.KeyID, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(key.E)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding key %s: %s", key.KeyID, err)
+		}
+		keys[key.KeyID] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("jwks file has no RSA signing keys")
+	}
+	return &JWTAuthenticator{keys: keys}, nil
+}
+
+type jwtHeader struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+}
+
+type jwtClaims struct {
+	Subject   string          `json:"sub"`
+	Issuer    string          `json:"iss"`
+	Audience  json.RawMessage `json:"aud"`
+	ExpiresAt *int64          `json:"exp"`
+	NotBefore *int64          `json:"nbf"`
+}
+
+func (claims *jwtClaims) hasAudience(audience string) bool {
+	var single string
+	if json.Unmarshal(claims.Audience, &single) == nil {
+		return single == audience
+	}
+	var multiple []string
+	json.Unmarshal(claims.Audience, &multiple)
+	for _, candidate := range multiple {
+		if candidate == audience {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticate verifies the token and returns its subject
+func (a *JWTAuthenticator) Authenticate(token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", fmt.Errorf("malformed token")
+	}
+	var header jwtHeader
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return "", err
+	}
+	hash, ok := jwtAlgorithms[header.Algorithm]
+	if !ok {
+		return "", fmt.Errorf("unsupported token algorithm %s", header.Algorithm)
+	}
+	key, ok := a.keys[header.KeyID]
+	if !ok {
+		return "", fmt.Errorf("unknown token key %s", header.KeyID)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", fmt.Errorf("malformed token signature")
+	}
+	hasher := hash.New()
+	hasher.Write([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, hash, hasher.Sum(nil), signature); err != nil {
+		return "", fmt.Errorf("invalid token signature")
+	}
+
+	var claims jwtClaims
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return "", err
+	}
+	now := time.Now()
+	if claims.ExpiresAt == nil || now.After(time.Unix(*claims.ExpiresAt, 0).Add(jwtLeeway)) {
+		return "", fmt.Errorf("token expired")
+	}
+	if claims.NotBefore != nil && now.Before(time.Unix(*claims.NotBefore, 0).Add(-jwtLeeway)) {
+		return "", fmt.Errorf("token not valid yet")
+	}
+	if a.Issuer != "" && claims.Issuer != a.Issuer {
+		return "", fmt.Errorf("token issuer %s not accepted", claims.Issuer)
+	}
+	if a.Audience != "" && !claims.hasAudience(a.Audience) {
+		return "", fmt.Errorf("token audience not accepted")
+	}
+	if claims.Subject == "" {
+		return "", fmt.Errorf("token has no subject")
+	}
+	return claims.Subject, nil
+}
+
+func decodeJWTPart(part string, v interface{}) error {
+	decoded, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return fmt.Errorf("malformed token")
+	}
+	if err := json.Unmarshal(decoded, v); err != nil {
+		return fmt.Errorf("malformed token")
+	}
+	return nil
+}
+
+// Authenticators tries each authenticator in turn. JWTs are only given
+// to JWT authenticators and other tokens only to the rest.
+type Authenticators []Authenticator
+
+// Authenticate returns the principal of the first authenticator that
+// accepts the token
+func (authenticators Authenticators) Authenticate(token string) (string, error) {
+	isJWT := strings.Count(token, ".") == 2
+	err := fmt.Errorf("invalid token")
+	for _, authenticator := range authenticators {
+		if _, ok := authenticator.(*JWTAuthenticator); ok != isJWT {
+			continue
+		}
+		principal, authErr := authenticator.Authenticate(token)
+		if authErr == nil {
+			return principal, nil
+		}
+		err = authErr
+	}
+	return "", err
+}
@@ -0,0 +1,229 @@
+package server
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tskinn/envi/internal/storetest"
+	"github.com/tskinn/envi/store"
+)
+
+func writeTempFile(t *testing.T, content string) string {
+	file, err := ioutil.TempFile("", "envi")
+	if err != nil {
+		t.Fatalf("error creating temp file %s", err)
+	}
+	defer file.Close()
+	if _, err := file.WriteString(content); err != nil {
+		t.Fatalf("error writing temp file %s", err)
+	}
+	return file.Name()
+}
+
+func signJWT(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	sum := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+	if err != nil {
+		t.Fatalf("error signing token %s", err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func newTestJWTAuthenticator(t *testing.T) (*JWTAuthenticator, *rsa.PrivateKey) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("error generating key %s", err)
+	}
+	jwks, _ := json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "test",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}},
+	})
+	fileName := writeTempFile(t, string(jwks))
+	defer os.Remove(fileName)
+	authenticator, err := LoadJWTAuthenticator(fileName)
+	if err != nil {
+		t.Fatalf("error loading jwks %s", err)
+	}
+	return authenticator, key
+}
+
+func TestTokenAuthenticator(t *testing.T) {
+	fileName := writeTempFile(t, `tokens:
+  - principal: ci-bot
+    token: plain-token
+  - principal: dashboard
+    sha256: 9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08
+`)
+	defer os.Remove(fileName)
+	authenticator, err := LoadTokenAuthenticator(fileName)
+	if err != nil {
+		t.Fatalf("error loading tokens %s", err)
+	}
+	if principal, err := authenticator.Authenticate("plain-token"); err != nil || principal != "ci-bot" {
+		t.Fatalf("expected ci-bot, got %s %v", principal, err)
+	}
+	if principal, err := authenticator.Authenticate("test"); err != nil || principal != "dashboard" {
+		t.Fatalf("expected dashboard, got %s %v", principal, err)
+	}
+	if _, err := authenticator.Authenticate("wrong"); err == nil {
+		t.Fatalf("expected error for wrong token")
+	}
+}
+
+func TestJWTAuthenticator(t *testing.T) {
+	authenticator, key := newTestJWTAuthenticator(t)
+	authenticator.Audience = "envi"
+	valid := map[string]interface{}{
+		"sub": "alice",
+		"aud": []string{"other", "envi"},
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	if principal, err := authenticator.Authenticate(signJWT(t, key, "test", valid)); err != nil || principal != "alice" {
+		t.Fatalf("expected alice, got %s %v", principal, err)
+	}
+
+	expired := map[string]interface{}{"sub": "alice", "aud": "envi", "exp": time.Now().Add(-time.Hour).Unix()}
+	if _, err := authenticator.Authenticate(signJWT(t, key, "test", expired)); err == nil {
+		t.Fatalf("expected error for expired token")
+	}
+	wrongAudience := map[string]interface{}{"sub": "alice", "aud": "other", "exp": time.Now().Add(time.Hour).Unix()}
+	if _, err := authenticator.Authenticate(signJWT(t, key, "test", wrongAudience)); err == nil {
+		t.Fatalf("expected error for wrong audience")
+	}
+	otherKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	if _, err := authenticator.Authenticate(signJWT(t, otherKey, "test", valid)); err == nil {
+		t.Fatalf("expected error for token signed by another key")
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	fileName := writeTempFile(t, `rules:
+  - principals: [alice]
+    ids: ["billing__*"]
+    actions: [read, write]
+  - principals: ["*"]
+    ids: ["shared"]
+    actions: [read]
+`)
+	defer os.Remove(fileName)
+	policy, err := LoadPolicy(fileName)
+	if err != nil {
+		t.Fatalf("error loading policy %s", err)
+	}
+	tests := []struct {
+		principal, id, action string
+		allowed               bool
+	}{
+		{"alice", "billing__prod", ActionWrite, true},
+		{"alice", "billing__prod", ActionReadSecrets, false},
+		{"alice", "shipping__prod", ActionRead, false},
+		{"bob", "shared", ActionRead, true},
+		{"bob", "shared", ActionWrite, false},
+	}
+	for _, test := range tests {
+		if policy.Allowed(test.principal, test.id, test.action) != test.allowed {
+			t.Fatalf("expected %s %s %s to be allowed=%t", test.principal, test.action, test.id, test.allowed)
+		}
+	}
+
+	badFileName := writeTempFile(t, "rules:\n  - principals: [alice]\n    ids: [x]\n    actions: [admin]\n")
+	defer os.Remove(badFileName)
+	if _, err := LoadPolicy(badFileName); err == nil {
+		t.Fatalf("expected error for unknown action")
+	}
+}
+
+func TestServerAuthorization(t *testing.T) {
+	client, _ := storetest.NewClient(t)
+	storetest.SaveItem(t, client, "billing__prod", "DB_HOST=db", "DB_PASSWORD=hunter2")
+	storetest.SaveItem(t, client, "shipping__prod", "DB_HOST=db")
+	authenticator, key := newTestJWTAuthenticator(t)
+	policy := &Policy{Rules: []Rule{
+		{Principals: []string{"alice"}, IDs: []string{"billing__*"}, Actions: []string{ActionRead}},
+		{Principals: []string{"admin"}, IDs: []string{"*"}, Actions: []string{ActionRead, ActionWrite, ActionReadSecrets}},
+		{Principals: []string{"ci-bot"}, IDs: []string{"billing__*"}, Actions: []string{ActionWrite}},
+	}}
+	server := httptest.NewServer(New(Options{Client: client, Authenticator: authenticator, Policy: policy}))
+	defer server.Close()
+	token := func(principal string) string {
+		return signJWT(t, key, "test", map[string]interface{}{"sub": principal, "exp": time.Now().Add(time.Hour).Unix()})
+	}
+	request := func(method, path, token string) (int, store.Item) {
+		r, _ := http.NewRequest(method, server.URL+path, nil)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		response, err := http.DefaultClient.Do(r)
+		if err != nil {
+			t.Fatalf("error doing request %s", err)
+		}
+		defer response.Body.Close()
+		var item store.Item
+		json.NewDecoder(response.Body).Decode(&item)
+		return response.StatusCode, item
+	}
+
+	if status, _ := request(http.MethodGet, "/v1/configs/billing__prod", ""); status != http.StatusUnauthorized {
+		t.Fatalf("expected unauthorized without token, got %d", status)
+	}
+	status, item := request(http.MethodGet, "/v1/configs/billing__prod", token("alice"))
+	if status != http.StatusOK || item.Variables[1].Value != store.Mask {
+		t.Fatalf("expected masked secret, got %d %v", status, item)
+	}
+	if status, _ := request(http.MethodGet, "/v1/configs/shipping__prod", token("alice")); status != http.StatusForbidden {
+		t.Fatalf("expected forbidden reading another app, got %d", status)
+	}
+	if status, _ := request(http.MethodDelete, "/v1/configs/billing__prod", token("alice")); status != http.StatusForbidden {
+		t.Fatalf("expected forbidden writing without write, got %d", status)
+	}
+	status, item = request(http.MethodGet, "/v1/configs/billing__prod", token("admin"))
+	if status != http.StatusOK || item.Variables[1].Value != "hunter2" {
+		t.Fatalf("expected revealed secret, got %d %v", status, item)
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, server.URL+"/v1/configs", nil)
+	r.Header.Set("Authorization", "Bearer "+token("alice"))
+	response, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatalf("error doing request %s", err)
+	}
+	defer response.Body.Close()
+	var items []store.Item
+	json.NewDecoder(response.Body).Decode(&items)
+	if len(items) != 1 || items[0].ID != "billing__prod" {
+		t.Fatalf("expected only readable configs to be listed %v", items)
+	}
+
+	// a principal that may only write doesn't get the config back
+	r, _ = http.NewRequest(http.MethodPatch, server.URL+"/v1/configs/billing__prod", strings.NewReader(`{"variables": [{"name": "DB_HOST", "value": "db2"}]}`))
+	r.Header.Set("Authorization", "Bearer "+token("ci-bot"))
+	response, err = http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatalf("error doing request %s", err)
+	}
+	defer response.Body.Close()
+	body, _ := ioutil.ReadAll(response.Body)
+	if response.StatusCode != http.StatusNoContent || len(body) != 0 {
+		t.Fatalf("expected no content writing without read, got %d %s", response.StatusCode, body)
+	}
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"slices"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// Actions a principal can be allowed to take on configs
+const (
+	// ActionRead allows reading configs with secrets masked
+	ActionRead = "read"
+	// ActionWrite allows creating, changing and deleting configs
+	ActionWrite = "write"
+	// ActionReadSecrets allows reading the values of secrets
+	ActionReadSecrets = "read-secrets"
+)
+
+// Policy maps principals to the actions they are allowed to take on
+// configs
+type Policy struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// Rule allows principals to take actions on the configs whose ids match
+// one of the patterns. Principals and ids use the syntax of path.Match so
+// "*" matches every principal and billing__* every billing config.
+type Rule struct {
+	Principals []string `yaml:"principals"`
+	IDs        []string `yaml:"ids"`
+	Actions    []string `yaml:"actions"`
+}
+
+// unmarshalStrict unmarshals yaml failing on fields that v doesn't have
+func unmarshalStrict(content []byte, v interface{}) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(v); err != nil && err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+// LoadPolicy reads a yaml policy file of the form
+//
+//	rules:
+//	  - principals: [alice, ci-bot]
+//	    ids: ["billing__*"]
+//	    actions: [read, write, read-secrets]
+func LoadPolicy(fileName string) (*Policy, error) {
+	content, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	var policy Policy
+	if err := unmarshalStrict(content, &policy); err != nil {
+		return nil, fmt.Errorf("error parsing policy file: %s", err)
+	}
+	for i, rule := range policy.Rules {
+		for _, action := range rule.Actions {
+			if action != ActionRead && action != ActionWrite && action != ActionReadSecrets {
+				return nil, fmt.Errorf("rule %d: unknown action %s", i, action)
+			}
+		}
+		for _, pattern := range append(append([]string{}, rule.Principals...), rule.IDs...) {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("rule %d: bad pattern %s", i, pattern)
+			}
+		}
+	}
+	return &policy, nil
+}
+
+// Allowed reports whether principal may take action on the config id
+func (policy *Policy) Allowed(principal, id, action string) bool {
+	for _, rule := range policy.Rules {
+		if matchesAny(rule.Principals, principal) && matchesAny(rule.IDs, id) && slices.Contains(rule.Actions, action) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,269 @@
+// Package server serves application configurations from the store over
+// http
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/tskinn/envi/store"
+)
+
+const configsPath = "/v1/configs"
+
+// maximum size of a request body. Items in dynamodb are at most 400KB.
+const maxBodySize = 1024 * 1024
+
+// Options configure the server
+type Options struct {
+	// Client is the store the configs are served from
+	Client *store.Client
+	// Authenticator authenticates the bearer token of every request.
+	// Requests aren't authenticated if it is nil.
+	Authenticator Authenticator
+	// Policy authorizes the requests of authenticated principals. If it
+	// is nil every request may read and write but secrets are always
+	// masked.
+	Policy *Policy
+	// SecretPatterns match the names of variables that are only returned
+	// to principals allowed to read-secrets. store.DefaultSecretPatterns
+	// are used if empty.
+	SecretPatterns []string
+}
+
+type server struct {
+	options Options
+}
+
+type principalKey struct{}
+
+// New returns a handler serving the REST api:
+//
+//	GET    /v1/configs?prefix=   list configs whose ids start with prefix
+//	GET    /v1/configs/{id}      get a config
+//	PUT    /v1/configs/{id}      replace the variables of a config like set
+//	PATCH  /v1/configs/{id}      insert and update variables like update;
+//	                             ?create=true creates a missing config
+//	DELETE /v1/configs/{id}      delete a config, or only the variables
+//	                             named in ?variables=a,b
+//
+// Configs are written and returned in the same json shape as
+// store.Item. Writes respond with the config as it is stored after the
+// write, or with no content if the principal may not read it.
+func New(options Options) http.Handler {
+	if len(options.SecretPatterns) == 0 {
+		options.SecretPatterns = store.DefaultSecretPatterns
+	}
+	s := &server{options: options}
+	mux := http.NewServeMux()
+	mux.HandleFunc(configsPath, s.handleList)
+	mux.HandleFunc(configsPath+"/", s.handleConfig)
+	return s.authenticate(mux)
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "\t")
+	encoder.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
+
+// errorStatus returns the http status of an error of the store
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, store.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, store.ErrValidation):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// authenticate rejects requests without a valid bearer token and passes
+// the principal of the token on in the request context
+func (s *server) authenticate(next http.Handler) http.Handler {
+	if s.options.Authenticator == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		if !strings.HasPrefix(header, "Bearer ") {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, fmt.Errorf("missing bearer token"))
+			return
+		}
+		principal, err := s.options.Authenticator.Authenticate(strings.TrimPrefix(header, "Bearer "))
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, err)
+			return
+		}
+		ctx := store.WithActor(context.WithValue(r.Context(), principalKey{}, principal), principal)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// allowed reports whether the principal of the request may take action
+// on the config id. Only a policy can allow reading secrets.
+func (s *server) allowed(r *http.Request, id, action string) bool {
+	if s.options.Policy == nil {
+		return action != ActionReadSecrets
+	}
+	principal, _ := r.Context().Value(principalKey{}).(string)
+	return s.options.Policy.Allowed(principal, id, action)
+}
+
+// redact masks the secrets of the item unless the principal of the
+// request may read them. Secrets are the variables matching the secret
+// patterns and those declared secret by the schema of the application,
+// which are cached by application in schemaSecrets.
+func (s *server) redact(r *http.Request, item store.Item, schemaSecrets map[string][]string) (store.Item, error) {
+	if s.allowed(r, item.ID, ActionReadSecrets) {
+		return item, nil
+	}
+	app := store.AppFromID(item.ID)
+	secrets, cached := schemaSecrets[app]
+	if !cached {
+		var err error
+		if secrets, err = s.options.Client.SchemaSecrets(r.Context(), app); err != nil {
+			return item, err
+		}
+		schemaSecrets[app] = secrets
+	}
+	return item.MaskSecrets(s.options.SecretPatterns, secrets...), nil
+}
+
+func (s *server) handleList(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	items, err := s.options.Client.List(r.Context(), r.URL.Query().Get("prefix"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	// only list the configs the principal may read
+	readable := make([]store.Item, 0, len(items))
+	schemaSecrets := make(map[string][]string)
+	for _, item := range items {
+		if !s.allowed(r, item.ID, ActionRead) {
+			continue
+		}
+		item, err := s.redact(r, item, schemaSecrets)
+		if err != nil {
+			writeError(w, errorStatus(err), err)
+			return
+		}
+		readable = append(readable, item)
+	}
+	writeJSON(w, http.StatusOK, readable)
+}
+
+func (s *server) handleConfig(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, configsPath+"/")
+	if id == "" || strings.Contains(id, "/") {
+		writeError(w, http.StatusNotFound, fmt.Errorf("not found"))
+		return
+	}
+
+	action := ActionWrite
+	if r.Method == http.MethodGet {
+		action = ActionRead
+	}
+	if !s.allowed(r, id, action) {
+		writeError(w, http.StatusForbidden, fmt.Errorf("not allowed to %s %s", action, id))
+		return
+	}
+
+	var err error
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPatch:
+		var item store.Item
+		item, err = readItem(r, id)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if r.Method == http.MethodPut {
+			err = s.options.Client.Save(r.Context(), item)
+		} else {
+			err = s.options.Client.Update(r.Context(), item, r.URL.Query().Get("create") == "true")
+		}
+	case http.MethodDelete:
+		if names := r.URL.Query().Get("variables"); names != "" {
+			err = s.options.Client.DeleteVars(r.Context(), id, strings.Split(names, ","))
+		} else {
+			err = s.options.Client.Delete(r.Context(), id)
+			if err == nil {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+		}
+	default:
+		w.Header().Set("Allow", "GET, PUT, PATCH, DELETE")
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	// respond with the config as it is stored now, if the principal may
+	// read it
+	if !s.allowed(r, id, ActionRead) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	item, err := s.options.Client.Get(r.Context(), id)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	item, err = s.redact(r, item, make(map[string][]string))
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, item)
+}
+
+// readItem reads an item from the request body. The id of the item is
+// taken from the path and may be left out of the body.
+func readItem(r *http.Request, id string) (store.Item, error) {
+	var item store.Item
+	decoder := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxBodySize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&item); err != nil {
+		return item, fmt.Errorf("error parsing body: %s", err)
+	}
+	if item.ID != "" && item.ID != id {
+		return item, fmt.Errorf("id in body %s doesn't match id in path %s", item.ID, id)
+	}
+	item.ID = id
+	for _, variable := range item.Variables {
+		if variable.Name == "" {
+			return item, fmt.Errorf("variables must have a name")
+		}
+	}
+	return item, nil
+}
@@ -0,0 +1,165 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tskinn/envi/internal/storetest"
+	"github.com/tskinn/envi/store"
+)
+
+func newTestServer(t *testing.T) (*httptest.Server, *store.Client, storetest.DB) {
+	client, mock := storetest.NewClient(t)
+	return httptest.NewServer(New(Options{Client: client})), client, mock
+}
+
+func doRequest(t *testing.T, method, url, body string) (*http.Response, store.Item) {
+	request, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("error creating request %s", err)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatalf("error doing request %s", err)
+	}
+	defer response.Body.Close()
+	var item store.Item
+	json.NewDecoder(response.Body).Decode(&item)
+	return response, item
+}
+
+func TestPutPatchGet(t *testing.T) {
+	server, _, _ := newTestServer(t)
+	defer server.Close()
+
+	response, item := doRequest(t, http.MethodPut, server.URL+"/v1/configs/app__prod",
+		`{"variables": [{"name": "one", "value": "two"}, {"name": "three", "value": "four"}]}`)
+	if response.StatusCode != http.StatusOK || item.ID != "app__prod" || len(item.Variables) != 2 {
+		t.Fatalf("unexpected put response %d %v", response.StatusCode, item)
+	}
+	response, item = doRequest(t, http.MethodPatch, server.URL+"/v1/configs/app__prod",
+		`{"id": "app__prod", "variables": [{"name": "one", "value": "ten"}]}`)
+	if response.StatusCode != http.StatusOK || len(item.Variables) != 2 || item.Variables[0].Value != "ten" {
+		t.Fatalf("unexpected patch response %d %v", response.StatusCode, item)
+	}
+	response, item = doRequest(t, http.MethodGet, server.URL+"/v1/configs/app__prod", "")
+	if response.StatusCode != http.StatusOK || item.Variables[1].Value != "four" {
+		t.Fatalf("unexpected get response %d %v", response.StatusCode, item)
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	server, _, _ := newTestServer(t)
+	defer server.Close()
+
+	response, _ := doRequest(t, http.MethodGet, server.URL+"/v1/configs/app__missing", "")
+	if response.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected not found getting a missing config, got %d", response.StatusCode)
+	}
+	body := `{"variables": [{"name": "one", "value": "two"}]}`
+	response, _ = doRequest(t, http.MethodPatch, server.URL+"/v1/configs/app__missing", body)
+	if response.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected not found patching a missing config, got %d", response.StatusCode)
+	}
+	response, item := doRequest(t, http.MethodPatch, server.URL+"/v1/configs/app__missing?create=true", body)
+	if response.StatusCode != http.StatusOK || len(item.Variables) != 1 {
+		t.Fatalf("unexpected patch response with create %d %v", response.StatusCode, item)
+	}
+}
+
+func TestPutBadBody(t *testing.T) {
+	server, _, _ := newTestServer(t)
+	defer server.Close()
+
+	for _, body := range []string{`not json`, `{"id": "other__prod"}`, `{"variables": [{"value": "x"}]}`, `{"vars": []}`} {
+		response, _ := doRequest(t, http.MethodPut, server.URL+"/v1/configs/app__prod", body)
+		if response.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected bad request for %s, got %d", body, response.StatusCode)
+		}
+	}
+}
+
+func TestDelete(t *testing.T) {
+	server, client, mock := newTestServer(t)
+	defer server.Close()
+	storetest.SaveItem(t, client, "app__prod", "one=two", "three=four")
+
+	response, item := doRequest(t, http.MethodDelete, server.URL+"/v1/configs/app__prod?variables=one", "")
+	if response.StatusCode != http.StatusOK || len(item.Variables) != 1 {
+		t.Fatalf("unexpected delete variables response %d %v", response.StatusCode, item)
+	}
+	response, _ = doRequest(t, http.MethodDelete, server.URL+"/v1/configs/app__prod", "")
+	if response.StatusCode != http.StatusNoContent || len(mock.Items) != 0 {
+		t.Fatalf("unexpected delete response %d", response.StatusCode)
+	}
+}
+
+func TestList(t *testing.T) {
+	server, client, _ := newTestServer(t)
+	defer server.Close()
+	storetest.SaveItem(t, client, "app__prod", "one=two")
+	storetest.SaveItem(t, client, "app__dev", "one=two")
+	storetest.SaveItem(t, client, "other__prod", "one=two")
+
+	response, err := http.Get(server.URL + "/v1/configs?prefix=app__")
+	if err != nil {
+		t.Fatalf("error doing request %s", err)
+	}
+	defer response.Body.Close()
+	var items []store.Item
+	if err := json.NewDecoder(response.Body).Decode(&items); err != nil {
+		t.Fatalf("error decoding response %s", err)
+	}
+	if response.StatusCode != http.StatusOK || len(items) != 2 {
+		t.Fatalf("unexpected list response %d %v", response.StatusCode, items)
+	}
+}
+
+func TestSchemaSecretsMasked(t *testing.T) {
+	client, _ := storetest.NewClient(t)
+	if err := client.SaveSchema(context.Background(), "billing", strings.NewReader("variables:\n  LICENSE:\n    secret: true\n")); err != nil {
+		t.Fatalf("error saving schema %s", err)
+	}
+	storetest.SaveItem(t, client, "billing__prod", "DB_HOST=db", "LICENSE=abc123")
+	// everyone may read but not read secrets
+	policy := &Policy{Rules: []Rule{{Principals: []string{"*"}, IDs: []string{"*"}, Actions: []string{ActionRead}}}}
+	server := httptest.NewServer(New(Options{Client: client, Policy: policy}))
+	defer server.Close()
+
+	response, item := doRequest(t, http.MethodGet, server.URL+"/v1/configs/billing__prod", "")
+	if response.StatusCode != http.StatusOK || item.Variables[0].Value != "db" || item.Variables[1].Value != store.Mask {
+		t.Fatalf("expected the secret of the schema to be masked %d %v", response.StatusCode, item)
+	}
+
+	response, err := http.Get(server.URL + "/v1/configs")
+	if err != nil {
+		t.Fatalf("error doing request %s", err)
+	}
+	defer response.Body.Close()
+	var items []store.Item
+	if err := json.NewDecoder(response.Body).Decode(&items); err != nil {
+		t.Fatalf("error decoding response %s", err)
+	}
+	if len(items) != 1 || items[0].Variables[1].Value != store.Mask {
+		t.Fatalf("expected the secret of the schema to be masked when listing %v", items)
+	}
+}
+
+func TestSecretsMaskedWithoutPolicy(t *testing.T) {
+	server, _, _ := newTestServer(t)
+	defer server.Close()
+
+	response, item := doRequest(t, http.MethodPut, server.URL+"/v1/configs/app__prod",
+		`{"variables": [{"name": "DB_HOST", "value": "db"}, {"name": "DB_PASSWORD", "value": "hunter2"}]}`)
+	if response.StatusCode != http.StatusOK || item.Variables[1].Value != store.Mask {
+		t.Fatalf("expected the secret to be masked writing without a policy %d %v", response.StatusCode, item)
+	}
+	response, item = doRequest(t, http.MethodGet, server.URL+"/v1/configs/app__prod", "")
+	if response.StatusCode != http.StatusOK || item.Variables[0].Value != "db" || item.Variables[1].Value != store.Mask {
+		t.Fatalf("expected the secret to be masked reading without a policy %d %v", response.StatusCode, item)
+	}
+}
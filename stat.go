@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/tskinn/envi/store"
+	"github.com/urfave/cli"
+)
+
+func statCommand() cli.Command {
+	var top int
+	command := cli.Command{
+		Name:  "stat",
+		Usage: "show how much space an application configuration takes in dynamodb",
+		Action: func(c *cli.Context) error {
+			if id == "" {
+				return usageErrorf("must provide an id")
+			}
+			if top < 0 {
+				return usageErrorf("top can't be negative")
+			}
+			if err := initStore(); err != nil {
+				return err
+			}
+			stat, err := store.GetStat(id)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("id:        %s\n", stat.ID)
+			fmt.Printf("variables: %d\n", stat.Variables)
+			fmt.Printf("size:      %d bytes (%d%% of the %d byte item limit)\n", stat.Size, stat.Size*100/store.MaxItemSize, store.MaxItemSize)
+			if stat.Chunks > 0 {
+				fmt.Printf("chunks:    %d\n", stat.Chunks)
+			}
+			if top > len(stat.Largest) {
+				top = len(stat.Largest)
+			}
+			if top > 0 {
+				fmt.Println("largest:")
+			}
+			for _, variable := range stat.Largest[:top] {
+				fmt.Printf("  %-30s %d bytes\n", variable.Name, variable.Size)
+			}
+			return nil
+		},
+		Flags: []cli.Flag{
+			cli.IntFlag{
+				Name:        "top",
+				Value:       5,
+				Usage:       "number of largest variables to show",
+				Destination: &top,
+			},
+		},
+	}
+	return command
+}
@@ -0,0 +1,386 @@
+package store
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+// Actions of audit events
+const (
+	AuditSave       = "save"
+	AuditUpdate     = "update"
+	AuditDeleteVars = "delete_vars"
+	AuditDelete     = "delete"
+	AuditRenameVar  = "rename_var"
+	AuditRename     = "rename"
+	AuditApply      = "apply"
+	AuditImport     = "import"
+	AuditSaveSchema = "save_schema"
+)
+
+// Kinds of changes of audit events
+const (
+	ChangeAdded   = "added"
+	ChangeUpdated = "updated"
+	ChangeRemoved = "removed"
+)
+
+// auditTimeFormat has a fixed width so that times sort as strings
+const auditTimeFormat = "2006-01-02T15:04:05.000000000Z"
+
+// Audit configures the recording of an audit event for every change to
+// a config or schema, however it is made. The events are
+// appended to File or stored in the dynamodb table Table, which must
+// have a string hash key named id and a string range key named time.
+type Audit struct {
+	File  string
+	Table string
+	// Actor is who makes the changes. An actor in the context of a
+	// change takes precedence and the ARN of the aws caller identity is
+	// used if neither is set.
+	Actor string
+	// SecretPatterns match the names of variables whose values are
+	// left out of events, as are the values of the secrets declared by
+	// the schema of the application. DefaultSecretPatterns are used if
+	// empty.
+	SecretPatterns []string
+	// HashKey keys the HMACs of the values of secrets recorded in events
+	// so that changes can be told apart. No hashes are recorded if empty.
+	HashKey []byte
+}
+
+// enabled reports whether events are recorded
+func (audit *Audit) enabled() bool {
+	return audit.File != "" || audit.Table != ""
+}
+
+// AuditEvent is a change to a config
+type AuditEvent struct {
+	Time    time.Time     `json:"time"`
+	ID      string        `json:"id"`
+	Action  string        `json:"action"`
+	Actor   string        `json:"actor"`
+	Changes []AuditChange `json:"changes"`
+}
+
+// AuditChange is a change to a variable
+type AuditChange struct {
+	Name string `json:"name" dynamodbav:"name"`
+	// Change is ChangeAdded, ChangeUpdated or ChangeRemoved
+	Change string `json:"change" dynamodbav:"change"`
+	// Value is the new value of a variable that isn't a secret
+	Value string `json:"value,omitempty" dynamodbav:"value,omitempty"`
+	// Secret is set if the variable is a secret whose value is left out
+	Secret bool `json:"secret,omitempty" dynamodbav:"secret,omitempty"`
+	// Hash is the HMAC-SHA256 of the new value of a secret, keyed with
+	// the HashKey of the audit
+	Hash string `json:"hash,omitempty" dynamodbav:"hash,omitempty"`
+}
+
+// WithAudit sets how the changes made by the client are audited
+func WithAudit(audit Audit) Option {
+	return func(client *Client) {
+		client.audit = audit
+	}
+}
+
+// SetAudit sets how the changes made by the package level functions are
+// audited
+func SetAudit(audit Audit) {
+	replaceDefaultClient(func(client *Client) {
+		client.audit = audit
+	})
+}
+
+type actorKey struct{}
+
+// WithActor returns a context for changes made by actor, e.g. the
+// authenticated principal of a request
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// callerIdentity is the ARN of the aws caller identity, looked up once
+type callerIdentity struct {
+	once sync.Once
+	arn  string
+	err  error
+}
+
+// actor returns who makes the changes in ctx
+func (c *Client) actor(ctx context.Context) (string, error) {
+	if actor, _ := ctx.Value(actorKey{}).(string); actor != "" {
+		return actor, nil
+	}
+	if c.audit.Actor != "" {
+		return c.audit.Actor, nil
+	}
+	if c.sts == nil {
+		return "unknown", nil
+	}
+	c.identity.once.Do(func() {
+		resp, err := c.sts.GetCallerIdentityWithContext(ctx, &sts.GetCallerIdentityInput{})
+		if err != nil {
+			c.identity.err = fmt.Errorf("looking up the caller identity: %w", err)
+			return
+		}
+		c.identity.arn = aws.StringValue(resp.Arn)
+	})
+	return c.identity.arn, c.identity.err
+}
+
+// auditing returns the variables of the item with id 'id' before a
+// change, if changes are audited
+func (c *Client) auditing(ctx context.Context, id string) ([]Variable, error) {
+	if !c.audit.enabled() {
+		return nil, nil
+	}
+	item, _, err := c.lookup(ctx, id)
+	return item.Variables, err
+}
+
+// record records an audit event for the change of the variables of the
+// item with id 'id' from before to after, if changes are audited
+func (c *Client) record(ctx context.Context, action, id string, before, after []Variable) error {
+	if !c.audit.enabled() {
+		return nil
+	}
+	event := AuditEvent{
+		Time:   time.Now().UTC(),
+		ID:     id,
+		Action: action,
+	}
+	var schemaSecrets []string
+	var err error
+	if !isSchemaID(id) {
+		schemaSecrets, err = c.SchemaSecrets(ctx, AppFromID(id))
+	}
+	if err == nil {
+		event.Changes = c.auditChanges(before, after, schemaSecrets)
+		event.Actor, err = c.actor(ctx)
+	}
+	if err == nil {
+		if c.audit.Table != "" {
+			err = c.recordInTable(ctx, event)
+		} else {
+			err = recordInFile(c.audit.File, event)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("%s was changed but the audit event wasn't recorded: %w", id, err)
+	}
+	return nil
+}
+
+// auditChanges returns the changes from before to after, leaving out the
+// values of variables matching the secret patterns of the audit or
+// named in schemaSecrets
+func (c *Client) auditChanges(before, after []Variable, schemaSecrets []string) []AuditChange {
+	patterns := c.audit.SecretPatterns
+	if len(patterns) == 0 {
+		patterns = DefaultSecretPatterns
+	}
+	changes := make([]AuditChange, 0)
+	change := func(kind string, variable Variable) {
+		auditChange := AuditChange{Name: variable.Name, Change: kind}
+		switch {
+		case kind == ChangeRemoved:
+		case IsSecret(variable.Name, patterns) || slices.Contains(schemaSecrets, variable.Name):
+			auditChange.Secret = true
+			if len(c.audit.HashKey) > 0 {
+				mac := hmac.New(sha256.New, c.audit.HashKey)
+				mac.Write([]byte(variable.Value))
+				auditChange.Hash = hex.EncodeToString(mac.Sum(nil))
+			}
+		default:
+			auditChange.Value = variable.Value
+		}
+		changes = append(changes, auditChange)
+	}
+	added, updated, removed, _ := diffVariables(before, after, true)
+	for _, variable := range added {
+		change(ChangeAdded, variable)
+	}
+	for _, variable := range updated {
+		change(ChangeUpdated, variable)
+	}
+	for _, variable := range removed {
+		change(ChangeRemoved, variable)
+	}
+	return changes
+}
+
+// recordInFile appends the event to the file as a line of json
+func recordInFile(path string, event AuditEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	// a single write so that events of concurrent processes aren't
+	// interleaved
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func (c *Client) recordInTable(ctx context.Context, event AuditEvent) error {
+	changes, err := dynamodbattribute.Marshal(event.Changes)
+	if err != nil {
+		return err
+	}
+	_, err = c.db.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(c.audit.Table),
+		Item: map[string]*dynamodb.AttributeValue{
+			"id":      {S: aws.String(event.ID)},
+			"time":    {S: aws.String(event.Time.Format(auditTimeFormat))},
+			"action":  {S: aws.String(event.Action)},
+			"actor":   {S: aws.String(event.Actor)},
+			"changes": changes,
+		},
+		// events are never overwritten
+		ConditionExpression:      aws.String("attribute_not_exists(#time)"),
+		ExpressionAttributeNames: map[string]*string{"#time": aws.String("time")},
+	})
+	return err
+}
+
+// AuditEvents returns the audit events of the config with id 'id', or of
+// every config if id is empty, since the time since, oldest first
+func AuditEvents(id string, since time.Time) ([]AuditEvent, error) {
+	return defaultClient.AuditEvents(context.Background(), id, since)
+}
+
+// AuditEvents returns the audit events of the config with id 'id', or of
+// every config if id is empty, since the time since, oldest first
+func (c *Client) AuditEvents(ctx context.Context, id string, since time.Time) ([]AuditEvent, error) {
+	var events []AuditEvent
+	var err error
+	switch {
+	case c.audit.Table != "":
+		events, err = c.tableEvents(ctx, id, since)
+	case c.audit.File != "":
+		events, err = fileEvents(c.audit.File, id, since)
+	default:
+		return nil, validationErrorf("changes aren't audited; set an audit file or table")
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Time.Before(events[j].Time)
+	})
+	return events, err
+}
+
+func fileEvents(path, id string, since time.Time) ([]AuditEvent, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var events []AuditEvent
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), maxExportLineSize)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		var event AuditEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return events, fmt.Errorf("%s line %d: %w", path, lineNumber, err)
+		}
+		if (id == "" || event.ID == id) && !event.Time.Before(since) {
+			events = append(events, event)
+		}
+	}
+	return events, scanner.Err()
+}
+
+func (c *Client) tableEvents(ctx context.Context, id string, since time.Time) ([]AuditEvent, error) {
+	names := map[string]*string{"#time": aws.String("time")}
+	values := map[string]*dynamodb.AttributeValue{
+		":since": {S: aws.String(since.UTC().Format(auditTimeFormat))},
+		":id":    {S: aws.String(id)},
+	}
+	// the events of a config are queried, all events are scanned
+	query := &dynamodb.QueryInput{
+		TableName:                 aws.String(c.audit.Table),
+		KeyConditionExpression:    aws.String("id = :id AND #time >= :since"),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+	}
+	scan := &dynamodb.ScanInput{
+		TableName:                 aws.String(c.audit.Table),
+		FilterExpression:          aws.String("#time >= :since"),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":since": values[":since"]},
+	}
+
+	var events []AuditEvent
+	for {
+		var items []map[string]*dynamodb.AttributeValue
+		if id != "" {
+			resp, err := c.db.QueryWithContext(ctx, query)
+			if err != nil {
+				return events, err
+			}
+			items, query.ExclusiveStartKey = resp.Items, resp.LastEvaluatedKey
+		} else {
+			resp, err := c.db.ScanWithContext(ctx, scan)
+			if err != nil {
+				return events, err
+			}
+			items, scan.ExclusiveStartKey = resp.Items, resp.LastEvaluatedKey
+		}
+		for _, attributes := range items {
+			event, err := eventFromItem(attributes)
+			if err != nil {
+				return events, err
+			}
+			events = append(events, event)
+		}
+		if query.ExclusiveStartKey == nil && scan.ExclusiveStartKey == nil {
+			return events, nil
+		}
+	}
+}
+
+func eventFromItem(attributes map[string]*dynamodb.AttributeValue) (AuditEvent, error) {
+	var event AuditEvent
+	var err error
+	if value := attributes["time"]; value != nil {
+		if event.Time, err = time.Parse(auditTimeFormat, aws.StringValue(value.S)); err != nil {
+			return event, err
+		}
+	}
+	for name, field := range map[string]*string{"id": &event.ID, "action": &event.Action, "actor": &event.Actor} {
+		if value := attributes[name]; value != nil {
+			*field = aws.StringValue(value.S)
+		}
+	}
+	if value := attributes["changes"]; value != nil {
+		err = dynamodbattribute.Unmarshal(value, &event.Changes)
+	}
+	return event, err
+}
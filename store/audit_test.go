@@ -0,0 +1,276 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func TestAuditFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "envi-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+	mock := mockDynamoDBClient{items: map[string]map[string]*dynamodb.AttributeValue{}}
+	SetDB(mock)
+	SetAudit(Audit{File: path, Actor: "alice"})
+	defer SetAudit(Audit{})
+
+	start := time.Now().Add(-time.Second)
+	if err := Save("app__prod", "one=two,DB_PASSWORD=hunter2"); err != nil {
+		t.Fatalf("error saving %s", err)
+	}
+	if err := UpdateItem(CreateItem("app__prod", []Variable{{Name: "one", Value: "three"}}), false); err != nil {
+		t.Fatalf("error updating %s", err)
+	}
+	if err := DeleteVars("app__prod", "one"); err != nil {
+		t.Fatalf("error deleting vars %s", err)
+	}
+	if err := defaultClient.Delete(WithActor(context.Background(), "ci-bot"), "app__prod"); err != nil {
+		t.Fatalf("error deleting %s", err)
+	}
+	if err := Save("app__dev", "one=two"); err != nil {
+		t.Fatalf("error saving %s", err)
+	}
+
+	events, err := AuditEvents("app__prod", start)
+	if err != nil {
+		t.Fatalf("error getting audit events %s", err)
+	}
+	if len(events) != 4 {
+		t.Fatalf("expected 4 events, got %d", len(events))
+	}
+	for i, action := range []string{AuditSave, AuditUpdate, AuditDeleteVars, AuditDelete} {
+		if events[i].Action != action || events[i].ID != "app__prod" {
+			t.Fatalf("unexpected event %d %+v", i, events[i])
+		}
+	}
+	saved := events[0].Changes
+	if len(saved) != 2 || saved[0].Name != "one" || saved[0].Value != "two" {
+		t.Fatalf("unexpected changes of save %+v", saved)
+	}
+	if saved[1].Name != "DB_PASSWORD" || saved[1].Value != "" || !saved[1].Secret || saved[1].Hash != "" {
+		t.Fatalf("expected the secret to be left out %+v", saved[1])
+	}
+	if changes := events[1].Changes; len(changes) != 1 || changes[0].Change != ChangeUpdated || changes[0].Value != "three" {
+		t.Fatalf("unexpected changes of update %+v", changes)
+	}
+	if changes := events[2].Changes; len(changes) != 1 || changes[0].Change != ChangeRemoved || changes[0].Name != "one" {
+		t.Fatalf("unexpected changes of delete vars %+v", changes)
+	}
+	if events[0].Actor != "alice" || events[3].Actor != "ci-bot" {
+		t.Fatalf("expected the actor of the context to take precedence %s %s", events[0].Actor, events[3].Actor)
+	}
+	if changes := events[3].Changes; len(changes) != 1 || changes[0].Name != "DB_PASSWORD" || changes[0].Hash != "" {
+		t.Fatalf("unexpected changes of delete %+v", changes)
+	}
+
+	all, err := AuditEvents("", start)
+	if err != nil || len(all) != 5 {
+		t.Fatalf("expected the events of every config %d %v", len(all), err)
+	}
+	recent, err := AuditEvents("", time.Now().Add(time.Second))
+	if err != nil || len(recent) != 0 {
+		t.Fatalf("expected no events in the future %d %v", len(recent), err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("expected the audit file to only be readable by its owner %v %v", info, err)
+	}
+}
+
+func TestAuditHashKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "envi-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	mock := mockDynamoDBClient{items: map[string]map[string]*dynamodb.AttributeValue{}}
+	SetDB(mock)
+	SetAudit(Audit{File: filepath.Join(dir, "audit.log"), Actor: "alice", HashKey: []byte("key")})
+	defer SetAudit(Audit{})
+
+	if err := Save("app__prod", "DB_PASSWORD=hunter2"); err != nil {
+		t.Fatalf("error saving %s", err)
+	}
+	if err := Save("app__prod", "DB_PASSWORD=hunter3"); err != nil {
+		t.Fatalf("error saving %s", err)
+	}
+	events, err := AuditEvents("app__prod", time.Time{})
+	if err != nil || len(events) != 2 {
+		t.Fatalf("expected two events %d %v", len(events), err)
+	}
+	first, second := events[0].Changes[0], events[1].Changes[0]
+	// the HMAC-SHA256 of hunter2 keyed with key
+	if first.Hash != "05d210d8af05129cb4bc04565faa72f94362ebaf20427cdf098059b5429d95bf" {
+		t.Fatalf("expected the secret to be hashed %+v", first)
+	}
+	if !first.Secret || first.Value != "" || second.Hash == first.Hash {
+		t.Fatalf("expected the hashes to tell the changes apart %+v %+v", first, second)
+	}
+}
+
+func TestAuditSchemaSecrets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "envi-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	mock := mockDynamoDBClient{items: map[string]map[string]*dynamodb.AttributeValue{}}
+	SetDB(mock)
+	SetAudit(Audit{File: filepath.Join(dir, "audit.log"), Actor: "alice", SecretPatterns: []string{"*_KEY"}})
+	defer SetAudit(Audit{})
+
+	if err := SaveSchema("app", strings.NewReader("variables:\n  DSN:\n    secret: true\n")); err != nil {
+		t.Fatalf("error saving schema %s", err)
+	}
+	if err := Save("app__prod", "DSN=postgres://u:hunter2@db,SIGNING_KEY=abc,PORT=8080"); err != nil {
+		t.Fatalf("error saving %s", err)
+	}
+	events, err := AuditEvents("app__prod", time.Time{})
+	if err != nil || len(events) != 1 || len(events[0].Changes) != 3 {
+		t.Fatalf("expected one event with three changes %+v %v", events, err)
+	}
+	for _, change := range events[0].Changes {
+		secret := change.Name != "PORT"
+		if change.Secret != secret || (secret && change.Value != "") || (!secret && change.Value != "8080") {
+			t.Fatalf("expected only the secrets of the schema and patterns to be left out %+v", change)
+		}
+	}
+}
+
+func TestAuditDisabled(t *testing.T) {
+	mock := mockDynamoDBClient{items: map[string]map[string]*dynamodb.AttributeValue{}}
+	SetDB(mock)
+	if _, err := AuditEvents("app__prod", time.Time{}); !errors.Is(err, ErrValidation) {
+		t.Fatalf("expected a validation error without auditing, got %v", err)
+	}
+}
+
+// mockAuditClient keeps the events of the audit table apart from configs
+type mockAuditClient struct {
+	mockDynamoDBClient
+	events *[]map[string]*dynamodb.AttributeValue
+}
+
+func (m mockAuditClient) PutItemWithContext(ctx aws.Context, input *dynamodb.PutItemInput, opts ...request.Option) (*dynamodb.PutItemOutput, error) {
+	if aws.StringValue(input.TableName) != "envi-audit" {
+		return m.mockDynamoDBClient.PutItemWithContext(ctx, input, opts...)
+	}
+	*m.events = append(*m.events, input.Item)
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (m mockAuditClient) QueryWithContext(ctx aws.Context, input *dynamodb.QueryInput, opts ...request.Option) (*dynamodb.QueryOutput, error) {
+	output := &dynamodb.QueryOutput{}
+	for _, event := range *m.events {
+		if *event["id"].S == *input.ExpressionAttributeValues[":id"].S && *event["time"].S >= *input.ExpressionAttributeValues[":since"].S {
+			output.Items = append(output.Items, event)
+		}
+	}
+	return output, nil
+}
+
+func TestAuditTable(t *testing.T) {
+	mock := mockAuditClient{
+		mockDynamoDBClient: mockDynamoDBClient{items: map[string]map[string]*dynamodb.AttributeValue{}},
+		events:             new([]map[string]*dynamodb.AttributeValue),
+	}
+	client, err := NewClient(WithTable("envi"), WithDB(mock), WithAudit(Audit{Table: "envi-audit", Actor: "alice"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := client.Save(ctx, CreateItem("app__prod", []Variable{{Name: "one", Value: "two"}})); err != nil {
+		t.Fatalf("error saving %s", err)
+	}
+	if err := client.Delete(ctx, "app__prod"); err != nil {
+		t.Fatalf("error deleting %s", err)
+	}
+	if len(*mock.events) != 2 || len(mock.items) != 0 {
+		t.Fatalf("expected the events in the audit table %d %d", len(*mock.events), len(mock.items))
+	}
+	events, err := client.AuditEvents(ctx, "app__prod", time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("error getting audit events %s", err)
+	}
+	if len(events) != 2 || events[0].Action != AuditSave || events[1].Action != AuditDelete || events[0].Actor != "alice" {
+		t.Fatalf("unexpected events %+v", events)
+	}
+	if changes := events[0].Changes; len(changes) != 1 || changes[0].Name != "one" || changes[0].Value != "two" {
+		t.Fatalf("unexpected changes %+v", changes)
+	}
+}
+
+func TestAuditEveryWritePath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "envi-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	mock := mockDynamoDBClient{items: map[string]map[string]*dynamodb.AttributeValue{}}
+	SetDB(mock)
+	SetAudit(Audit{File: filepath.Join(dir, "audit.log"), Actor: "alice"})
+	defer SetAudit(Audit{})
+
+	if err := Save("app__prod", "one=two"); err != nil {
+		t.Fatalf("error saving %s", err)
+	}
+	if err := RenameVar("app__prod", "one", "uno"); err != nil {
+		t.Fatalf("error renaming variable %s", err)
+	}
+	if err := Rename("app__prod", "app__live"); err != nil {
+		t.Fatalf("error renaming %s", err)
+	}
+	changes, err := Plan([]Item{CreateItem("app__live", []Variable{{Name: "three", Value: "four"}})}, false)
+	if err != nil {
+		t.Fatalf("error planning %s", err)
+	}
+	if err := ApplyChanges(changes); err != nil {
+		t.Fatalf("error applying %s", err)
+	}
+	if _, err := Import(strings.NewReader(`{"id": "app__dev", "variables": [{"name": "five", "value": "six"}]}` + "\n")); err != nil {
+		t.Fatalf("error importing %s", err)
+	}
+	if err := SaveSchema("app", strings.NewReader("variables: {}\n")); err != nil {
+		t.Fatalf("error saving schema %s", err)
+	}
+
+	events, err := AuditEvents("", time.Time{})
+	if err != nil {
+		t.Fatalf("error getting audit events %s", err)
+	}
+	expected := []struct{ id, action string }{
+		{"app__prod", AuditSave},
+		{"app__prod", AuditRenameVar},
+		{"app__prod", AuditRename},
+		{"app__live", AuditRename},
+		{"app__live", AuditApply},
+		{"app__dev", AuditImport},
+		{"_schema__app", AuditSaveSchema},
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("expected %d events, got %+v", len(expected), events)
+	}
+	for i, want := range expected {
+		if events[i].ID != want.id || events[i].Action != want.action {
+			t.Fatalf("event %d is %s %s, expected %s %s", i, events[i].Action, events[i].ID, want.action, want.id)
+		}
+	}
+	if changes := events[1].Changes; len(changes) != 2 || changes[0].Name != "uno" || changes[1].Change != ChangeRemoved {
+		t.Fatalf("unexpected changes of rename var %+v", changes)
+	}
+	if changes := events[4].Changes; len(changes) != 1 || changes[0].Name != "three" || changes[0].Change != ChangeAdded {
+		t.Fatalf("unexpected changes of apply %+v", changes)
+	}
+}
@@ -0,0 +1,329 @@
+package store
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+const (
+	// maximum number of requests in a single BatchWriteItem call
+	batchWriteLimit = 25
+	// maximum number of times unprocessed or throttled writes are retried
+	batchWriteRetries = 10
+	// maximum size of a line in an export. Items are at most 400KB but
+	// values are base64 encoded in dynamodb and escaped in json.
+	maxExportLineSize = 4 * 1024 * 1024
+)
+
+// batchWriteBackoff is the initial wait before retrying unprocessed
+// writes. It doubles on every retry.
+var batchWriteBackoff = 50 * time.Millisecond
+
+// scan calls fn with every item whose id starts with prefix
+func (c *Client) scan(ctx context.Context, prefix string, fn func(Item) error) error {
+	params := &dynamodb.ScanInput{
+		TableName: aws.String(c.tableName),
+	}
+	if prefix != "" {
+		params.FilterExpression = aws.String("begins_with(id, :prefix)")
+		params.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{
+			":prefix": {
+				S: aws.String(prefix),
+			},
+		}
+	}
+	for {
+		resp, err := c.db.ScanWithContext(ctx, params)
+		if err != nil {
+			return err
+		}
+		for _, attributes := range resp.Items {
+			if id := attributes["id"]; id != nil && isChunkID(aws.StringValue(id.S)) {
+				continue
+			}
+			item, err := c.unmarshalItem(ctx, attributes)
+			if err != nil {
+				return err
+			}
+			if err := fn(item); err != nil {
+				return err
+			}
+		}
+		if len(resp.LastEvaluatedKey) == 0 {
+			return nil
+		}
+		params.ExclusiveStartKey = resp.LastEvaluatedKey
+	}
+}
+
+// List returns every item whose id starts with prefix. All items are
+// returned if prefix is empty.
+func List(prefix string) ([]Item, error) {
+	return defaultClient.List(context.Background(), prefix)
+}
+
+// List returns every item whose id starts with prefix. All items are
+// returned if prefix is empty. The items holding schemas aren't returned.
+func (c *Client) List(ctx context.Context, prefix string) ([]Item, error) {
+	items := make([]Item, 0)
+	err := c.scan(ctx, prefix, func(item Item) error {
+		if !isSchemaID(item.ID) {
+			items = append(items, item)
+		}
+		return nil
+	})
+	return items, err
+}
+
+// Export writes every item whose id starts with prefix to w, one json
+// object per line, and returns the number of items written
+func Export(w io.Writer, prefix string) (int, error) {
+	return defaultClient.Export(context.Background(), w, prefix)
+}
+
+// Export writes every item whose id starts with prefix to w, one json
+// object per line, and returns the number of items written
+func (c *Client) Export(ctx context.Context, w io.Writer, prefix string) (int, error) {
+	count := 0
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	err := c.scan(ctx, prefix, func(item Item) error {
+		if err := encoder.Encode(item); err != nil {
+			return err
+		}
+		count++
+		return nil
+	})
+	return count, err
+}
+
+// ImportOptions configure how Import saves items
+type ImportOptions struct {
+	// Merge inserts new variables and updates existing variables of
+	// stored items like Update instead of replacing all of them
+	Merge bool
+}
+
+// Import reads items in the format written by Export from r and saves
+// them, overwriting items with the same id. It returns the number of
+// items imported.
+func Import(r io.Reader) (int, error) {
+	return defaultClient.Import(context.Background(), r)
+}
+
+// ImportWithOptions reads items in the format written by Export from r
+// and saves them as set by options. It returns the number of items
+// imported.
+func ImportWithOptions(r io.Reader, options ImportOptions) (int, error) {
+	return defaultClient.ImportWithOptions(context.Background(), r, options)
+}
+
+// Import reads items in the format written by Export from r and saves
+// them, overwriting items with the same id. Every item is checked
+// against the lint rules of the client and the schema of its
+// application, from r if it has one, before any is saved. The error is
+// ErrValidation if one doesn't pass. It returns the number of items
+// imported.
+func (c *Client) Import(ctx context.Context, r io.Reader) (int, error) {
+	return c.ImportWithOptions(ctx, r, ImportOptions{})
+}
+
+// ImportWithOptions is Import with the items merged into the stored
+// items if options.Merge is set
+func (c *Client) ImportWithOptions(ctx context.Context, r io.Reader, options ImportOptions) (int, error) {
+	items, err := c.readImport(ctx, r, options)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	batch := make([]*dynamodb.WriteRequest, 0, batchWriteLimit)
+	ids := make(map[string]bool)
+	// the items of the batch with their variables before the import, to
+	// audit once the batch is written
+	var batchItems []Item
+	var befores [][]Variable
+	flush := func() error {
+		if err := c.batchWrite(ctx, batch); err != nil {
+			return err
+		}
+		count += len(batch)
+		for i, item := range batchItems {
+			if err := c.record(ctx, AuditImport, item.ID, befores[i], item.Variables); err != nil {
+				return err
+			}
+		}
+		batch, batchItems, befores = batch[:0], batchItems[:0], befores[:0]
+		ids = make(map[string]bool)
+		return nil
+	}
+
+	for _, item := range items {
+		// a batch can't write the same id twice
+		if len(batch) == batchWriteLimit || ids[item.ID] {
+			if err := flush(); err != nil {
+				return count, err
+			}
+		}
+		rows, err := storedRows(item)
+		if err != nil {
+			return count, err
+		}
+		before, err := c.auditing(ctx, item.ID)
+		if err != nil {
+			return count, err
+		}
+		// chunked items are written with their chunks in a transaction
+		if len(rows) > 1 {
+			if err := c.write(ctx, item); err != nil {
+				return count, err
+			}
+			count++
+			if err := c.record(ctx, AuditImport, item.ID, before, item.Variables); err != nil {
+				return count, err
+			}
+			continue
+		}
+		batch = append(batch, &dynamodb.WriteRequest{
+			PutRequest: &dynamodb.PutRequest{Item: rows[0]},
+		})
+		batchItems, befores = append(batchItems, item), append(befores, before)
+		ids[item.ID] = true
+	}
+	if len(batch) > 0 {
+		return count, flush()
+	}
+	return count, nil
+}
+
+// readImport reads and checks the items of an import, merged with the
+// stored items if options.Merge is set. Schemas in r are checked before
+// the configs, which are checked against them, so that the order of an
+// export doesn't matter.
+func (c *Client) readImport(ctx context.Context, r io.Reader, options ImportOptions) ([]Item, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxExportLineSize)
+
+	var items []Item
+	var lines []int
+	schemas := make(map[string]Schema)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var item Item
+		if err := json.Unmarshal(scanner.Bytes(), &item); err != nil {
+			return nil, validationErrorf("line %d: %s", lineNumber, err)
+		}
+		switch {
+		case item.ID == "":
+			return nil, validationErrorf("line %d: item is missing an id", lineNumber)
+		case isChunkID(item.ID):
+			return nil, validationErrorf("line %d: ids can't start with %s", lineNumber, chunkPrefix)
+		case isSchemaID(item.ID):
+			raw, _ := findVariable(item.Variables, schemaVariable)
+			schema, err := ParseSchema(strings.NewReader(raw.Value))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+			}
+			schemas[strings.TrimPrefix(item.ID, schemaPrefix)] = schema
+		}
+		items, lines = append(items, item), append(lines, lineNumber)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// the variables of the items imported so far, so that an id imported
+	// twice is merged with its earlier line
+	imported := make(map[string][]Variable)
+	for i, item := range items {
+		if isSchemaID(item.ID) {
+			continue
+		}
+		if err := c.lint(item.Variables); err != nil {
+			return nil, fmt.Errorf("line %d: %w", lines[i], err)
+		}
+		if options.Merge {
+			vars, found := imported[item.ID]
+			if !found {
+				stored, _, err := c.lookup(ctx, item.ID)
+				if err != nil {
+					return nil, err
+				}
+				vars = stored.Variables
+			}
+			items[i].Variables = mergeVariables(vars, item.Variables)
+		}
+		imported[item.ID] = items[i].Variables
+		schema, found := schemas[AppFromID(item.ID)]
+		if !found {
+			var err error
+			if schema, found, err = c.schemaFor(ctx, item.ID); err != nil {
+				return nil, err
+			}
+		}
+		if found {
+			if err := schemaError(item.ID, schema.Check(items[i].Variables)); err != nil {
+				return nil, fmt.Errorf("line %d: %w", lines[i], err)
+			}
+		}
+	}
+	return items, nil
+}
+
+// batchWrite writes requests with BatchWriteItem, retrying unprocessed
+// and throttled writes with exponential backoff
+func (c *Client) batchWrite(ctx context.Context, requests []*dynamodb.WriteRequest) error {
+	backoff := batchWriteBackoff
+	pending := requests
+	for attempt := 0; len(pending) > 0; attempt++ {
+		if attempt > 0 {
+			if attempt > batchWriteRetries {
+				return fmt.Errorf("gave up writing %d items after %d retries", len(pending), batchWriteRetries)
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+		resp, err := c.db.BatchWriteItemWithContext(ctx, &dynamodb.BatchWriteItemInput{
+			RequestItems: map[string][]*dynamodb.WriteRequest{
+				c.tableName: pending,
+			},
+		})
+		if err != nil {
+			if isThrottle(err) {
+				continue
+			}
+			return err
+		}
+		pending = resp.UnprocessedItems[c.tableName]
+	}
+	return nil
+}
+
+func isThrottle(err error) bool {
+	if aerr, ok := err.(awserr.Error); ok {
+		switch aerr.Code() {
+		case dynamodb.ErrCodeProvisionedThroughputExceededException,
+			dynamodb.ErrCodeRequestLimitExceeded,
+			"ThrottlingException":
+			return true
+		}
+	}
+	return false
+}
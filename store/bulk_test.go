@@ -0,0 +1,131 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func TestList(t *testing.T) {
+	mock := mockDynamoDBClient{items: map[string]map[string]*dynamodb.AttributeValue{}}
+	SetDB(mock)
+	for _, id := range []string{"app__dev", "app__prod", "app__staging", "other__prod"} {
+		if err := Save(id, testRawVariables); err != nil {
+			t.Fatalf("error %s", err)
+		}
+	}
+	items, err := List("")
+	if err != nil {
+		t.Fatalf("error listing items %s", err)
+	}
+	if len(items) != 4 {
+		t.Fatalf("expected every item to be listed across pages, got %d", len(items))
+	}
+	if !variablesEqual(items[0].Variables, testItemOne.Variables) {
+		t.Fatalf("expected listed variables to be decoded %v", items[0].Variables)
+	}
+	items, err = List("app__")
+	if err != nil {
+		t.Fatalf("error listing items %s", err)
+	}
+	if len(items) != 3 {
+		t.Fatalf("expected three items with prefix, got %d", len(items))
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	mock := mockDynamoDBClient{items: map[string]map[string]*dynamodb.AttributeValue{}}
+	SetDB(mock)
+	for i := 0; i < 30; i++ {
+		if err := Save(fmt.Sprintf("app__%02d", i), testRawVariables); err != nil {
+			t.Fatalf("error %s", err)
+		}
+	}
+	var backup bytes.Buffer
+	count, err := Export(&backup, "")
+	if err != nil {
+		t.Fatalf("error exporting %s", err)
+	}
+	if count != 30 || strings.Count(backup.String(), "\n") != 30 {
+		t.Fatalf("expected 30 exported items, got %d", count)
+	}
+
+	restored := mockDynamoDBClient{items: map[string]map[string]*dynamodb.AttributeValue{}}
+	SetDB(restored)
+	count, err = Import(&backup)
+	if err != nil {
+		t.Fatalf("error importing %s", err)
+	}
+	if count != 30 || len(restored.items) != 30 {
+		t.Fatalf("expected 30 imported items, got %d", count)
+	}
+	item, err := Get("app__29")
+	if err != nil {
+		t.Fatalf("error getting item %s", err)
+	}
+	if !variablesEqual(item.Variables, testItemOne.Variables) {
+		t.Fatalf("imported variables don't match expected %v", item.Variables)
+	}
+}
+
+func TestImportBadLine(t *testing.T) {
+	mock := mockDynamoDBClient{items: map[string]map[string]*dynamodb.AttributeValue{}}
+	SetDB(mock)
+	_, err := Import(strings.NewReader("{\"id\": \"app__one\"}\nnot json\n"))
+	if err == nil || !strings.HasPrefix(err.Error(), "line 2") {
+		t.Fatalf("expected error on line 2, got %v", err)
+	}
+	if len(mock.items) != 0 {
+		t.Fatalf("expected nothing to be imported, got %d items", len(mock.items))
+	}
+}
+
+func TestImportMerge(t *testing.T) {
+	mock := mockDynamoDBClient{items: map[string]map[string]*dynamodb.AttributeValue{}}
+	SetDB(mock)
+	if err := Save("app__prod", "one=two,three=four"); err != nil {
+		t.Fatalf("error %s", err)
+	}
+	export := `{"id": "app__prod", "variables": [{"name": "three", "value": "five"}, {"name": "six", "value": "seven"}]}` + "\n"
+	if _, err := ImportWithOptions(strings.NewReader(export), ImportOptions{Merge: true}); err != nil {
+		t.Fatalf("error importing %s", err)
+	}
+	item, err := Get("app__prod")
+	if err != nil {
+		t.Fatalf("error getting item %s", err)
+	}
+	expected := []Variable{{Name: "one", Value: "two"}, {Name: "three", Value: "five"}, {Name: "six", Value: "seven"}}
+	if !variablesEqual(item.Variables, expected) {
+		t.Fatalf("expected the import to be merged %v", item.Variables)
+	}
+	if _, err := Import(strings.NewReader(export)); err != nil {
+		t.Fatalf("error importing %s", err)
+	}
+	if item, _ := Get("app__prod"); len(item.Variables) != 2 {
+		t.Fatalf("expected the import to overwrite without merge %v", item.Variables)
+	}
+}
+
+func TestImportChecks(t *testing.T) {
+	mock := mockDynamoDBClient{items: map[string]map[string]*dynamodb.AttributeValue{}}
+	SetDB(mock)
+	tests := map[string]string{
+		"bad name": `{"id": "app__prod", "variables": [{"name": "1BAD", "value": "two"}]}`,
+		"chunk id": `{"id": "` + chunkPrefix + `app__prod", "variables": []}`,
+		// the schema is checked even though it comes after the config
+		"schema": `{"id": "app__prod", "variables": [{"name": "PORT", "value": "http"}]}` + "\n" +
+			`{"id": "_schema__app", "variables": [{"name": "schema", "value": "variables:\n  PORT:\n    type: int\n"}]}`,
+	}
+	for name, export := range tests {
+		if _, err := Import(strings.NewReader(export + "\n")); !errors.Is(err, ErrValidation) {
+			t.Fatalf("%s: expected a validation error, got %v", name, err)
+		}
+		if len(mock.items) != 0 {
+			t.Fatalf("%s: expected nothing to be imported", name)
+		}
+	}
+}
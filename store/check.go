@@ -0,0 +1,78 @@
+package store
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Drift is the difference between the stored variables of an item and
+// the variables actually in use by a process or in a file
+type Drift struct {
+	// Missing are stored variables that aren't in use
+	Missing []Variable
+	// Extra are variables in use that aren't stored
+	Extra []Variable
+	// Differing are variables in use whose values differ from the stored
+	// ones, with the stored values
+	Differing []Variable
+}
+
+// Empty reports whether there is no drift
+func (drift *Drift) Empty() bool {
+	return len(drift.Missing) == 0 && len(drift.Extra) == 0 && len(drift.Differing) == 0
+}
+
+// String describes the drift without revealing any values
+func (drift *Drift) String() string {
+	var buffer bytes.Buffer
+	for _, variable := range drift.Missing {
+		fmt.Fprintf(&buffer, "missing: %s\n", variable.Name)
+	}
+	for _, variable := range drift.Extra {
+		fmt.Fprintf(&buffer, "extra: %s\n", variable.Name)
+	}
+	for _, variable := range drift.Differing {
+		fmt.Fprintf(&buffer, "differs: %s\n", variable.Name)
+	}
+	return buffer.String()
+}
+
+// CheckDrift compares the stored variables with the ones actually in
+// use. Extra variables aren't reported if ignoreExtra is true, e.g. when
+// checking a process environment which has many unrelated variables.
+func CheckDrift(stored, actual []Variable, ignoreExtra bool) Drift {
+	var drift Drift
+	drift.Missing, drift.Differing, drift.Extra, _ = diffVariables(actual, stored, !ignoreExtra)
+	return drift
+}
+
+// ParseEnviron converts an environment in the form returned by
+// os.Environ into variables
+func ParseEnviron(environ []string) []Variable {
+	vars := make([]Variable, 0, len(environ))
+	for _, pair := range environ {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) == 2 && parts[0] != "" {
+			vars = append(vars, Variable{Name: parts[0], Value: parts[1]})
+		}
+	}
+	return vars
+}
+
+// ParseVariables parses variables in the form of this=that,this2=that2
+func ParseVariables(vars string) ([]Variable, error) {
+	return parseVariables(vars, false)
+}
+
+// ParseFile reads variables from the file fileName, or stdin if fileName
+// is "-", in any of the formats accepted by SaveFromFile
+func ParseFile(fileName, format string) ([]Variable, error) {
+	return parseVariablesFromFile(fileName, format, false)
+}
+
+// ParseFileUnlinted reads variables like ParseFile without checking them
+// against the lint rules, e.g. to compare a file with a config
+func ParseFileUnlinted(fileName, format string) ([]Variable, error) {
+	return readVariablesFile(fileName, format, false, nil)
+}
@@ -0,0 +1,62 @@
+package store
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckDrift(t *testing.T) {
+	actual := []Variable{
+		{Name: "one", Value: "two"},
+		{Name: "three", Value: "changed"},
+		{Name: "seven", Value: "eight"},
+	}
+	drift := CheckDrift(testItemOne.Variables, actual, false)
+	if len(drift.Missing) != 1 || drift.Missing[0].Name != "five" {
+		t.Fatalf("expected five to be missing %v", drift)
+	}
+	if len(drift.Extra) != 1 || drift.Extra[0].Name != "seven" {
+		t.Fatalf("expected seven to be extra %v", drift)
+	}
+	if len(drift.Differing) != 1 || drift.Differing[0].Name != "three" {
+		t.Fatalf("expected three to differ %v", drift)
+	}
+
+	drift = CheckDrift(testItemOne.Variables, actual, true)
+	if len(drift.Extra) != 0 {
+		t.Fatalf("expected extra variables to be ignored %v", drift)
+	}
+	drift = CheckDrift(testItemOne.Variables, testItemOne.Variables, false)
+	if !drift.Empty() {
+		t.Fatalf("expected no drift %v", drift)
+	}
+}
+
+func TestParseEnviron(t *testing.T) {
+	vars := ParseEnviron([]string{"one=two", "three=four=five", "=C:=C:\\"})
+	expected := []Variable{
+		{Name: "one", Value: "two"},
+		{Name: "three", Value: "four=five"},
+	}
+	if !variablesEqual(vars, expected) {
+		t.Fatalf("variables don't match expected %v", vars)
+	}
+}
+
+func TestParseFileUnlinted(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), ".env")
+	if err := ioutil.WriteFile(fileName, []byte("one=two\nBAD NAME=three\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseFile(fileName, ""); err == nil {
+		t.Fatalf("expected ParseFile to check the lint rules")
+	}
+	variables, err := ParseFileUnlinted(fileName, "")
+	if err != nil {
+		t.Fatalf("error parsing %s", err)
+	}
+	if len(variables) != 2 || variables[1].Name != "BAD NAME" {
+		t.Fatalf("unexpected variables %v", variables)
+	}
+}
@@ -0,0 +1,412 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// Items larger than DynamoDB's item size limit are stored in chunks. The
+// row with the id of the item only holds the number of chunks and the
+// variables, as json, are split across the rows _chunk__<id>#1 to
+// _chunk__<id>#<chunks>. The rows are always written and deleted
+// together in a transaction and all of them hold the revision of the
+// variables, so chunks read while the item changes can be told apart.
+const (
+	// MaxItemSize is the maximum size of a row in DynamoDB
+	MaxItemSize = 400 * 1024
+	// chunkSize is the maximum size of the variables stored in a row,
+	// leaving room for the id and attribute names
+	chunkSize = 350 * 1024
+	// maxChunks keeps the rows of an item within the 4MB a transaction
+	// can write
+	maxChunks = 10
+	// chunkPrefix starts the ids of the rows holding chunks
+	chunkPrefix = "_chunk__"
+	// chunksAttribute is the attribute of a chunked item holding the
+	// number of chunks
+	chunksAttribute = "chunks"
+	// chunkAttribute is the attribute of a chunk row holding its part
+	// of the variables
+	chunkAttribute = "chunk"
+	// revisionAttribute is the attribute of a head row, and of its
+	// chunks, holding the revision of the variables of the item
+	revisionAttribute = "revision"
+	// chunkReads is how many times the chunks of an item are read
+	// before giving up on the item changing while they are read
+	chunkReads = 3
+)
+
+// revision returns a digest of the variables that changes whenever they
+// do, so that a write can be made conditional on the variables being
+// the ones that were read
+func revision(vars []Variable) (string, error) {
+	if vars == nil {
+		vars = []Variable{}
+	}
+	data, err := json.Marshal(vars)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:16]), nil
+}
+
+// condition is the condition expression of a write
+type condition struct {
+	expression string
+	names      map[string]*string
+	values     map[string]*dynamodb.AttributeValue
+}
+
+var (
+	itemExists    = &condition{expression: "attribute_exists(id)"}
+	itemNotExists = &condition{expression: "attribute_not_exists(id)"}
+)
+
+// unchanged returns the condition that the item exists with the
+// variables vars. Rows stored before revisions were have none and pass.
+func unchanged(vars []Variable) (*condition, error) {
+	rev, err := revision(vars)
+	if err != nil {
+		return nil, err
+	}
+	return &condition{
+		expression: "attribute_exists(id) AND (attribute_not_exists(#revision) OR #revision = :revision)",
+		names:      map[string]*string{"#revision": aws.String(revisionAttribute)},
+		values:     map[string]*dynamodb.AttributeValue{":revision": {S: aws.String(rev)}},
+	}, nil
+}
+
+// chunkID returns the id of the nth chunk of the item with id 'id'
+func chunkID(id string, n int) string {
+	return chunkPrefix + id + "#" + strconv.Itoa(n)
+}
+
+// isChunkID reports whether id is the id of a row holding a chunk
+func isChunkID(id string) bool {
+	return strings.HasPrefix(id, chunkPrefix)
+}
+
+// itemSize returns the size DynamoDB counts for a row: the lengths of
+// the attribute names and values
+func itemSize(attributes map[string]*dynamodb.AttributeValue) int {
+	size := 0
+	for name, value := range attributes {
+		size += len(name) + attributeSize(value)
+	}
+	return size
+}
+
+func attributeSize(value *dynamodb.AttributeValue) int {
+	switch {
+	case value.S != nil:
+		return len(*value.S)
+	case value.N != nil:
+		return len(*value.N)
+	case value.B != nil:
+		return len(value.B)
+	case value.L != nil:
+		// lists and maps cost 3 bytes plus a byte per element
+		size := 3
+		for _, element := range value.L {
+			size += 1 + attributeSize(element)
+		}
+		return size
+	case value.M != nil:
+		size := 3
+		for name, element := range value.M {
+			size += 1 + len(name) + attributeSize(element)
+		}
+		return size
+	}
+	return 1
+}
+
+// storedRows returns the rows the item is stored in: the item itself if
+// it fits in a row, otherwise the head row followed by the chunks
+func storedRows(item Item) ([]map[string]*dynamodb.AttributeValue, error) {
+	// encode a copy so the caller's variables aren't encoded too
+	encoded := CreateItem(item.ID, append([]Variable(nil), item.Variables...))
+	encoded.encode()
+	attributes, err := dynamodbattribute.MarshalMap(encoded)
+	if err != nil {
+		return nil, err
+	}
+	rev, err := revision(item.Variables)
+	if err != nil {
+		return nil, err
+	}
+	attributes[revisionAttribute] = &dynamodb.AttributeValue{S: aws.String(rev)}
+	if itemSize(attributes) <= chunkSize {
+		return []map[string]*dynamodb.AttributeValue{attributes}, nil
+	}
+
+	data, err := json.Marshal(item.Variables)
+	if err != nil {
+		return nil, err
+	}
+	chunks := (len(data) + chunkSize - 1) / chunkSize
+	if chunks > maxChunks {
+		return nil, validationErrorf("%s is %d bytes, more than the maximum of %d", item.ID, len(data), maxChunks*chunkSize)
+	}
+	rows := []map[string]*dynamodb.AttributeValue{{
+		"id":              {S: aws.String(item.ID)},
+		chunksAttribute:   {N: aws.String(strconv.Itoa(chunks))},
+		revisionAttribute: {S: aws.String(rev)},
+	}}
+	for n := 1; n <= chunks; n++ {
+		end := n * chunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+		rows = append(rows, map[string]*dynamodb.AttributeValue{
+			"id":              {S: aws.String(chunkID(item.ID, n))},
+			chunkAttribute:    {B: data[(n-1)*chunkSize : end]},
+			revisionAttribute: {S: aws.String(rev)},
+		})
+	}
+	return rows, nil
+}
+
+// chunkCount returns the number of chunks attribute of a head row, 0 if
+// the item isn't chunked
+func chunkCount(attributes map[string]*dynamodb.AttributeValue) int {
+	value, ok := attributes[chunksAttribute]
+	if !ok || value.N == nil {
+		return 0
+	}
+	chunks, _ := strconv.Atoi(*value.N)
+	return chunks
+}
+
+// storedChunks returns the number of chunks of the stored item with id
+// 'id', 0 if it doesn't exist or isn't chunked
+func (c *Client) storedChunks(ctx context.Context, id string) (int, error) {
+	resp, err := c.db.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName:            aws.String(c.tableName),
+		Key:                  key(id),
+		ProjectionExpression: aws.String(chunksAttribute),
+	})
+	if err != nil {
+		return 0, err
+	}
+	return chunkCount(resp.Item), nil
+}
+
+// unmarshalItem converts a head row into an item, reading its chunks if
+// it has any. If the item changes while its chunks are read the head row
+// is read again and so are the chunks of the new revision.
+func (c *Client) unmarshalItem(ctx context.Context, attributes map[string]*dynamodb.AttributeValue) (Item, error) {
+	for reads := 1; ; reads++ {
+		item, current, err := c.readChunks(ctx, attributes)
+		if err != nil || current {
+			return item, err
+		}
+		if reads == chunkReads {
+			return item, conflictErrorf("%s changed every time its chunks were read", item.ID)
+		}
+		resp, err := c.db.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+			TableName:      aws.String(c.tableName),
+			Key:            key(item.ID),
+			ConsistentRead: aws.Bool(true),
+		})
+		if err != nil {
+			return item, err
+		}
+		if len(resp.Item) == 0 {
+			return item, notFoundErrorf("%s doesn't exist", item.ID)
+		}
+		attributes = resp.Item
+	}
+}
+
+// readChunks converts a head row into an item reading its chunks. It
+// reports whether the chunks are of the revision of the head row, which
+// they aren't if the item changed since the head row was read.
+func (c *Client) readChunks(ctx context.Context, attributes map[string]*dynamodb.AttributeValue) (Item, bool, error) {
+	var item Item
+	if err := dynamodbattribute.UnmarshalMap(attributes, &item); err != nil {
+		return item, false, err
+	}
+	chunks := chunkCount(attributes)
+	if chunks == 0 {
+		item.decode()
+		return item, true, nil
+	}
+	var rev string
+	if value, ok := attributes[revisionAttribute]; ok {
+		rev = aws.StringValue(value.S)
+	}
+	data := make([]byte, 0, chunks*chunkSize)
+	for n := 1; n <= chunks; n++ {
+		resp, err := c.db.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+			TableName:      aws.String(c.tableName),
+			Key:            key(chunkID(item.ID, n)),
+			ConsistentRead: aws.Bool(true),
+		})
+		if err != nil {
+			return item, false, err
+		}
+		chunk, ok := resp.Item[chunkAttribute]
+		if !ok {
+			// the item shrank since the head row was read
+			return item, false, nil
+		}
+		// rows written before they held revisions have none
+		if chunkRev, ok := resp.Item[revisionAttribute]; ok && rev != "" && aws.StringValue(chunkRev.S) != rev {
+			return item, false, nil
+		}
+		data = append(data, chunk.B...)
+	}
+	if err := json.Unmarshal(data, &item.Variables); err != nil {
+		return item, false, err
+	}
+	return item, true, nil
+}
+
+// writeActions returns the transaction actions storing the item: a put
+// of its head row with the condition, if there is one, puts of its
+// chunks and deletes of the chunks left over from a larger version of
+// the item
+func (c *Client) writeActions(ctx context.Context, item Item, cond *condition) ([]*dynamodb.TransactWriteItem, error) {
+	rows, err := storedRows(item)
+	if err != nil {
+		return nil, err
+	}
+	previous, err := c.storedChunks(ctx, item.ID)
+	if err != nil {
+		return nil, err
+	}
+	actions := make([]*dynamodb.TransactWriteItem, 0, len(rows))
+	for i, row := range rows {
+		put := &dynamodb.Put{
+			TableName: aws.String(c.tableName),
+			Item:      row,
+		}
+		if i == 0 && cond != nil {
+			put.ConditionExpression = aws.String(cond.expression)
+			put.ExpressionAttributeNames = cond.names
+			put.ExpressionAttributeValues = cond.values
+		}
+		actions = append(actions, &dynamodb.TransactWriteItem{Put: put})
+	}
+	for n := len(rows); n <= previous; n++ {
+		actions = append(actions, &dynamodb.TransactWriteItem{
+			Delete: &dynamodb.Delete{
+				TableName: aws.String(c.tableName),
+				Key:       key(chunkID(item.ID, n)),
+			},
+		})
+	}
+	return actions, nil
+}
+
+// deleteActions returns the transaction actions deleting the item with
+// id 'id' and its chunks. The condition, if there is one, is set on the
+// delete of the head row.
+func (c *Client) deleteActions(ctx context.Context, id string, cond *condition) ([]*dynamodb.TransactWriteItem, error) {
+	chunks, err := c.storedChunks(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	actions := make([]*dynamodb.TransactWriteItem, 0, chunks+1)
+	for n := 0; n <= chunks; n++ {
+		del := &dynamodb.Delete{
+			TableName: aws.String(c.tableName),
+			Key:       key(id),
+		}
+		if n == 0 && cond != nil {
+			del.ConditionExpression = aws.String(cond.expression)
+			del.ExpressionAttributeNames = cond.names
+			del.ExpressionAttributeValues = cond.values
+		}
+		if n > 0 {
+			del.Key = key(chunkID(id, n))
+		}
+		actions = append(actions, &dynamodb.TransactWriteItem{Delete: del})
+	}
+	return actions, nil
+}
+
+// write stores the item in a single put, or a transaction if it is or
+// was chunked
+func (c *Client) write(ctx context.Context, item Item) error {
+	actions, err := c.writeActions(ctx, item, nil)
+	if err != nil {
+		return err
+	}
+	if len(actions) == 1 {
+		_, err = c.db.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+			TableName: aws.String(c.tableName),
+			Item:      actions[0].Put.Item,
+		})
+		return err
+	}
+	_, err = c.db.TransactWriteItemsWithContext(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: actions,
+	})
+	return err
+}
+
+// Stat describes how a config is stored
+type Stat struct {
+	ID        string
+	Variables int
+	// Size is the number of bytes the config takes in DynamoDB
+	Size int
+	// Chunks is the number of rows the variables are split across, 0 if
+	// the config fits in a single row
+	Chunks int
+	// Largest are the variables sorted by size, largest first
+	Largest []VariableSize
+}
+
+// VariableSize is the number of bytes of the name and value of a
+// variable
+type VariableSize struct {
+	Name string
+	Size int
+}
+
+// GetStat returns how the config with id 'id' is stored
+func GetStat(id string) (Stat, error) {
+	return defaultClient.Stat(context.Background(), id)
+}
+
+// Stat returns how the config with id 'id' is stored. The error is
+// ErrNotFound if there is no such config.
+func (c *Client) Stat(ctx context.Context, id string) (Stat, error) {
+	item, err := c.Get(ctx, id)
+	if err != nil {
+		return Stat{}, err
+	}
+	rows, err := storedRows(item)
+	if err != nil {
+		return Stat{}, err
+	}
+	stat := Stat{
+		ID:        id,
+		Variables: len(item.Variables),
+		Chunks:    len(rows) - 1,
+		Largest:   make([]VariableSize, len(item.Variables)),
+	}
+	for _, row := range rows {
+		stat.Size += itemSize(row)
+	}
+	for i, variable := range item.Variables {
+		stat.Largest[i] = VariableSize{Name: variable.Name, Size: len(variable.Name) + len(variable.Value)}
+	}
+	sort.SliceStable(stat.Largest, func(i, j int) bool {
+		return stat.Largest[i].Size > stat.Largest[j].Size
+	})
+	return stat, nil
+}
@@ -0,0 +1,135 @@
+package store
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// largeVariables returns n variables with values of size bytes
+func largeVariables(n, size int) []Variable {
+	vars := make([]Variable, n)
+	for i := range vars {
+		vars[i] = Variable{Name: "VAR_" + string(rune('A'+i)), Value: strings.Repeat(string(rune('a'+i)), size)}
+	}
+	return vars
+}
+
+func TestChunkedItem(t *testing.T) {
+	mock := mockDynamoDBClient{items: map[string]map[string]*dynamodb.AttributeValue{}}
+	SetDB(mock)
+	vars := largeVariables(3, 200*1024)
+	if err := SaveItem(CreateItem("app__big", vars)); err != nil {
+		t.Fatalf("error saving large item %s", err)
+	}
+	if len(mock.items) != 3 {
+		t.Fatalf("expected a head row and two chunks, got %d rows", len(mock.items))
+	}
+	for id, row := range mock.items {
+		if size := itemSize(row); size > MaxItemSize {
+			t.Fatalf("%s is %d bytes", id, size)
+		}
+	}
+	item, err := Get("app__big")
+	if err != nil {
+		t.Fatalf("error getting large item %s", err)
+	}
+	if !variablesEqual(item.Variables, vars) {
+		t.Fatalf("large item wasn't reassembled")
+	}
+	items, err := List("")
+	if err != nil || len(items) != 1 || !variablesEqual(items[0].Variables, vars) {
+		t.Fatalf("expected chunks to be hidden from list %d %v", len(items), err)
+	}
+
+	stat, err := GetStat("app__big")
+	if err != nil {
+		t.Fatalf("error getting stat %s", err)
+	}
+	if stat.Chunks != 2 || stat.Variables != 3 || stat.Size < 600*1024 || stat.Largest[0].Size != 200*1024+5 {
+		t.Fatalf("unexpected stat %+v", stat)
+	}
+
+	// shrinking the item deletes its chunks
+	if err := Save("app__big", "one=two"); err != nil {
+		t.Fatalf("error saving small item %s", err)
+	}
+	if len(mock.items) != 1 {
+		t.Fatalf("expected chunks to be deleted, got %d rows", len(mock.items))
+	}
+	stat, err = GetStat("app__big")
+	if err != nil || stat.Chunks != 0 || stat.Variables != 1 {
+		t.Fatalf("unexpected stat %+v %v", stat, err)
+	}
+
+	if err := SaveItem(CreateItem("app__big", vars)); err != nil {
+		t.Fatalf("error saving large item %s", err)
+	}
+	if err := Rename("app__big", "app__moved"); err != nil {
+		t.Fatalf("error renaming large item %s", err)
+	}
+	if _, ok := mock.items[chunkID("app__big", 1)]; ok || len(mock.items) != 3 {
+		t.Fatalf("expected chunks to move with the item %d", len(mock.items))
+	}
+	if err := Delete("app__moved"); err != nil {
+		t.Fatalf("error deleting large item %s", err)
+	}
+	if len(mock.items) != 0 {
+		t.Fatalf("expected chunks to be deleted with the item, got %d rows", len(mock.items))
+	}
+}
+
+func TestChunkedItemTooLarge(t *testing.T) {
+	mock := mockDynamoDBClient{items: map[string]map[string]*dynamodb.AttributeValue{}}
+	SetDB(mock)
+	err := SaveItem(CreateItem("app__huge", largeVariables(20, 200*1024)))
+	if !errors.Is(err, ErrValidation) {
+		t.Fatalf("expected a validation error saving a huge item, got %v", err)
+	}
+}
+
+// staleHeadDynamoDBClient returns the head row 'head' the first time the
+// item with id 'id' is read, as if the item changed right after
+type staleHeadDynamoDBClient struct {
+	mockDynamoDBClient
+	id    string
+	head  map[string]*dynamodb.AttributeValue
+	reads *int
+}
+
+func (m staleHeadDynamoDBClient) GetItemWithContext(ctx aws.Context, input *dynamodb.GetItemInput, opts ...request.Option) (*dynamodb.GetItemOutput, error) {
+	if *input.Key["id"].S == m.id {
+		*m.reads++
+		if *m.reads == 1 {
+			return &dynamodb.GetItemOutput{Item: m.head}, nil
+		}
+	}
+	return m.mockDynamoDBClient.GetItemWithContext(ctx, input, opts...)
+}
+
+func TestChunkedItemChangedWhileRead(t *testing.T) {
+	mock := mockDynamoDBClient{items: map[string]map[string]*dynamodb.AttributeValue{}}
+	SetDB(mock)
+	if err := SaveItem(CreateItem("app__big", largeVariables(3, 200*1024))); err != nil {
+		t.Fatalf("error saving large item %s", err)
+	}
+	head := mock.items["app__big"]
+	vars := largeVariables(3, 210*1024)
+	if err := SaveItem(CreateItem("app__big", vars)); err != nil {
+		t.Fatalf("error saving large item %s", err)
+	}
+
+	reads := 0
+	SetDB(staleHeadDynamoDBClient{mockDynamoDBClient: mock, id: "app__big", head: head, reads: &reads})
+	item, err := Get("app__big")
+	if err != nil {
+		t.Fatalf("error getting large item %s", err)
+	}
+	if !variablesEqual(item.Variables, vars) || reads != 2 {
+		t.Fatalf("expected the chunks of the new head to be read, read the head %d times", reads)
+	}
+}
@@ -0,0 +1,151 @@
+package store
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
+)
+
+// Client reads and writes the application configurations stored in a
+// dynamodb table. A Client isn't modified after it is created so it is
+// safe for concurrent use.
+type Client struct {
+	region    string
+	tableName string
+	db        dynamodbiface.DynamoDBAPI
+	rules     LintRules
+	aws       AWSOptions
+	audit     Audit
+	// sts finds the caller identity recorded as the actor of audit
+	// events. It is nil if the client was given a db.
+	sts      stsiface.STSAPI
+	identity *callerIdentity
+}
+
+// AWSOptions configure how a client reaches dynamodb
+type AWSOptions struct {
+	// Endpoint overrides the dynamodb endpoint, e.g.
+	// http://localhost:8000 for DynamoDB Local or a VPC endpoint
+	Endpoint string
+	// Profile is the profile of the shared aws config and credentials
+	// files to use instead of the default one
+	Profile string
+	// RoleARN is a role assumed with the credentials of the profile
+	RoleARN string
+	// ExternalID is given when assuming RoleARN if the role requires it
+	ExternalID string
+	// MFASerial is the serial number or ARN of the MFA device needed to
+	// assume RoleARN. The token code is read from stdin.
+	MFASerial string
+}
+
+// Option configures a Client created by NewClient
+type Option func(*Client)
+
+// WithRegion sets the aws region of the table
+func WithRegion(region string) Option {
+	return func(client *Client) {
+		client.region = region
+	}
+}
+
+// WithTable sets the name of the table
+func WithTable(name string) Option {
+	return func(client *Client) {
+		client.tableName = name
+	}
+}
+
+// WithDB sets the dynamodb client used instead of creating one for the
+// region
+func WithDB(db dynamodbiface.DynamoDBAPI) Option {
+	return func(client *Client) {
+		client.db = db
+	}
+}
+
+// WithLintRules sets the rules checked on the variables that are saved.
+// DefaultLintRules are used otherwise.
+func WithLintRules(rules LintRules) Option {
+	return func(client *Client) {
+		client.rules = rules
+	}
+}
+
+// WithAWSOptions sets how the client reaches dynamodb. It has no effect
+// if WithDB is also given.
+func WithAWSOptions(options AWSOptions) Option {
+	return func(client *Client) {
+		client.aws = options
+	}
+}
+
+// NewClient creates a client for the table given with WithTable
+func NewClient(opts ...Option) (*Client, error) {
+	client := &Client{rules: DefaultLintRules, identity: &callerIdentity{}}
+	for _, opt := range opts {
+		opt(client)
+	}
+	if client.tableName == "" {
+		return nil, validationErrorf("must provide a table name")
+	}
+	if client.db == nil {
+		db, stsClient, err := connect(client.region, client.aws)
+		if err != nil {
+			return nil, err
+		}
+		client.db, client.sts = db, stsClient
+	}
+	return client, nil
+}
+
+// connect creates dynamodb and sts clients for the region
+func connect(region string, options AWSOptions) (*dynamodb.DynamoDB, stsiface.STSAPI, error) {
+	sesh, err := session.NewSessionWithOptions(session.Options{
+		Config:  aws.Config{Region: aws.String(region)},
+		Profile: options.Profile,
+		// roles and mfa devices configured in the profile work too
+		SharedConfigState:       session.SharedConfigEnable,
+		AssumeRoleTokenProvider: stscreds.StdinTokenProvider,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	credentials := &aws.Config{}
+	if options.RoleARN != "" {
+		credentials.Credentials = stscreds.NewCredentials(sesh, options.RoleARN, func(provider *stscreds.AssumeRoleProvider) {
+			if options.ExternalID != "" {
+				provider.ExternalID = aws.String(options.ExternalID)
+			}
+			if options.MFASerial != "" {
+				provider.SerialNumber = aws.String(options.MFASerial)
+				provider.TokenProvider = stscreds.StdinTokenProvider
+			}
+		})
+	}
+	// the endpoint is only for dynamodb so that sts can still be used
+	// to assume a role
+	endpoint := &aws.Config{}
+	if options.Endpoint != "" {
+		endpoint.Endpoint = aws.String(options.Endpoint)
+	}
+	return dynamodb.New(sesh, credentials, endpoint), sts.New(sesh, credentials), nil
+}
+
+// Table returns the name of the table of the client
+func (c *Client) Table() string {
+	return c.tableName
+}
+
+// key returns the primary key of the item with id 'id'
+func key(id string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"id": {
+			S: aws.String(id),
+		},
+	}
+}
@@ -0,0 +1,77 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func TestNewClient(t *testing.T) {
+	if _, err := NewClient(); !errors.Is(err, ErrValidation) {
+		t.Fatalf("expected a validation error without a table, got %v", err)
+	}
+	mock := mockDynamoDBClient{items: map[string]map[string]*dynamodb.AttributeValue{}}
+	client, err := NewClient(WithTable("other"), WithDB(mock))
+	if err != nil {
+		t.Fatalf("error %s", err)
+	}
+	ctx := context.Background()
+	if err := client.Save(ctx, testItemOne); err != nil {
+		t.Fatalf("error %s", err)
+	}
+	item, err := client.Get(ctx, testItemOne.ID)
+	if err != nil {
+		t.Fatalf("error %s", err)
+	}
+	if len(item.Variables) != len(testItemOne.Variables) {
+		t.Fatalf("expected %d variables, got %d", len(testItemOne.Variables), len(item.Variables))
+	}
+}
+
+func TestErrorKinds(t *testing.T) {
+	mock := mockDynamoDBClient{items: map[string]map[string]*dynamodb.AttributeValue{}}
+	client, err := NewClient(WithTable("envi"), WithDB(mock))
+	if err != nil {
+		t.Fatalf("error %s", err)
+	}
+	ctx := context.Background()
+	for _, id := range []string{"app__one", "app__two"} {
+		if err := client.Save(ctx, CreateItem(id, []Variable{{Name: "one", Value: "two"}})); err != nil {
+			t.Fatalf("error %s", err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		kind error
+	}{
+		{"rename to existing id", client.Rename(ctx, "app__one", "app__two"), ErrConflict},
+		{"rename missing variable", client.RenameVar(ctx, "app__one", "missing", "other"), ErrNotFound},
+		{"copy to itself", client.Copy(ctx, "app__one", "app__one", CopyOptions{}), ErrValidation},
+		{"parse error", Save("app__one", `one="unterminated`), ErrValidation},
+	}
+	for _, test := range tests {
+		if !errors.Is(test.err, test.kind) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.kind, test.err)
+		}
+	}
+}
+
+func TestConnect(t *testing.T) {
+	// keep the shared config of the machine out of the test
+	missing := filepath.Join(t.TempDir(), "missing")
+	t.Setenv("AWS_CONFIG_FILE", missing)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", missing)
+	db, _, err := connect("us-west-2", AWSOptions{Endpoint: "http://localhost:8000", RoleARN: "arn:aws:iam::123456789012:role/envi"})
+	if err != nil {
+		t.Fatalf("error connecting %s", err)
+	}
+	if db.Endpoint != "http://localhost:8000" || aws.StringValue(db.Config.Region) != "us-west-2" {
+		t.Fatalf("unexpected endpoint %s or region %s", db.Endpoint, aws.StringValue(db.Config.Region))
+	}
+}
@@ -0,0 +1,111 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"path"
+)
+
+// CopyOptions control which variables Copy copies and how
+type CopyOptions struct {
+	// Replace replaces all variables of the destination like Save.
+	// Otherwise variables are merged into the destination like Update.
+	Replace bool
+	// OnlyMissing only copies variables the destination doesn't have
+	OnlyMissing bool
+	// Include only copies variables whose names match one of these
+	// patterns. Patterns use the syntax of path.Match, e.g. DB_*
+	Include []string
+	// Exclude doesn't copy variables whose names match one of these
+	// patterns
+	Exclude []string
+}
+
+// Copy copies the variables of the item with id 'from' to the item with
+// id 'to'
+func Copy(from, to string, options CopyOptions) error {
+	return defaultClient.Copy(context.Background(), from, to, options)
+}
+
+// Copy copies the variables of the item with id 'from' to the item with
+// id 'to'
+func (c *Client) Copy(ctx context.Context, from, to string, options CopyOptions) error {
+	if from == to {
+		return validationErrorf("can't copy %s to itself", from)
+	}
+	if options.Replace && options.OnlyMissing {
+		return validationErrorf("can't replace and only copy missing variables at the same time")
+	}
+	source, err := c.Get(ctx, from)
+	if err != nil {
+		return err
+	}
+	if len(source.Variables) == 0 {
+		// don't wipe out the destination because of a typo
+		return notFoundErrorf("%s has no variables to copy", from)
+	}
+
+	vars, err := filterVariables(source.Variables, options.Include, options.Exclude)
+	if err != nil {
+		return err
+	}
+	if len(vars) == 0 {
+		// likewise for filters that don't match anything
+		return notFoundErrorf("no variables of %s match the filters", from)
+	}
+	if options.Replace {
+		return c.Save(ctx, CreateItem(to, vars))
+	}
+
+	if options.OnlyMissing {
+		destination, err := c.Get(ctx, to)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+		missing := make([]Variable, 0, len(vars))
+		for _, variable := range vars {
+			if !variableNamed(destination.Variables, variable.Name) {
+				missing = append(missing, variable)
+			}
+		}
+		vars = missing
+	}
+	return c.Update(ctx, CreateItem(to, vars), true)
+}
+
+// filterVariables returns the variables matching at least one of the
+// include patterns, or all if there are none, and none of the exclude
+// patterns
+func filterVariables(vars []Variable, include, exclude []string) ([]Variable, error) {
+	filtered := make([]Variable, 0, len(vars))
+	for _, variable := range vars {
+		included := len(include) == 0
+		for _, pattern := range include {
+			matched, err := path.Match(pattern, variable.Name)
+			if err != nil {
+				return nil, validationErrorf("bad include pattern %s: %s", pattern, err)
+			}
+			included = included || matched
+		}
+		for _, pattern := range exclude {
+			matched, err := path.Match(pattern, variable.Name)
+			if err != nil {
+				return nil, validationErrorf("bad exclude pattern %s: %s", pattern, err)
+			}
+			included = included && !matched
+		}
+		if included {
+			filtered = append(filtered, variable)
+		}
+	}
+	return filtered, nil
+}
+
+func variableNamed(vars []Variable, name string) bool {
+	for _, variable := range vars {
+		if variable.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+package store
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func TestCopyMerge(t *testing.T) {
+	mock := mockDynamoDBClient{items: map[string]map[string]*dynamodb.AttributeValue{}}
+	SetDB(mock)
+	if err := Save("app__staging", "one=two,three=four,five=six"); err != nil {
+		t.Fatalf("error %s", err)
+	}
+	if err := Save("app__prod", "one=prod,seven=eight"); err != nil {
+		t.Fatalf("error %s", err)
+	}
+	err := Copy("app__staging", "app__prod", CopyOptions{Exclude: []string{"f*"}})
+	if err != nil {
+		t.Fatalf("error copying %s", err)
+	}
+	item, err := Get("app__prod")
+	if err != nil {
+		t.Fatalf("error getting item %s", err)
+	}
+	expected := []Variable{
+		{Name: "one", Value: "two"},
+		{Name: "seven", Value: "eight"},
+		{Name: "three", Value: "four"},
+	}
+	if !variablesEqual(item.Variables, expected) {
+		t.Fatalf("variables don't match expected %v", item.Variables)
+	}
+}
+
+func TestCopyReplace(t *testing.T) {
+	mock := mockDynamoDBClient{items: map[string]map[string]*dynamodb.AttributeValue{}}
+	SetDB(mock)
+	if err := Save("app__staging", "one=two,three=four,five=six"); err != nil {
+		t.Fatalf("error %s", err)
+	}
+	if err := Save("app__prod", "seven=eight"); err != nil {
+		t.Fatalf("error %s", err)
+	}
+	err := Copy("app__staging", "app__prod", CopyOptions{Replace: true, Include: []string{"one", "three"}})
+	if err != nil {
+		t.Fatalf("error copying %s", err)
+	}
+	item, err := Get("app__prod")
+	if err != nil {
+		t.Fatalf("error getting item %s", err)
+	}
+	if !variablesEqual(item.Variables, testItemOne.Variables[:2]) {
+		t.Fatalf("variables don't match expected %v", item.Variables)
+	}
+
+	// filters matching nothing don't wipe out the destination
+	err = Copy("app__staging", "app__prod", CopyOptions{Replace: true, Include: []string{"TYPO*"}})
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected not found copying no variables, got %v", err)
+	}
+	if item, err := Get("app__prod"); err != nil || len(item.Variables) != 2 {
+		t.Fatalf("expected the destination to be unchanged %v %v", item.Variables, err)
+	}
+}
+
+func TestCopyOnlyMissing(t *testing.T) {
+	mock := mockDynamoDBClient{items: map[string]map[string]*dynamodb.AttributeValue{}}
+	SetDB(mock)
+	if err := Save("app__staging", "one=two,three=four"); err != nil {
+		t.Fatalf("error %s", err)
+	}
+	if err := Save("app__prod", "one=prod"); err != nil {
+		t.Fatalf("error %s", err)
+	}
+	err := Copy("app__staging", "app__prod", CopyOptions{OnlyMissing: true})
+	if err != nil {
+		t.Fatalf("error copying %s", err)
+	}
+	item, err := Get("app__prod")
+	if err != nil {
+		t.Fatalf("error getting item %s", err)
+	}
+	expected := []Variable{
+		{Name: "one", Value: "prod"},
+		{Name: "three", Value: "four"},
+	}
+	if !variablesEqual(item.Variables, expected) {
+		t.Fatalf("variables don't match expected %v", item.Variables)
+	}
+
+	err = Copy("app__staging", "app__new", CopyOptions{OnlyMissing: true})
+	if err != nil {
+		t.Fatalf("error copying to new id %s", err)
+	}
+}
+
+func TestCopyOptionsConflict(t *testing.T) {
+	err := Copy("app__staging", "app__prod", CopyOptions{Replace: true, OnlyMissing: true})
+	if err == nil {
+		t.Fatalf("expected error when replacing only missing variables")
+	}
+}
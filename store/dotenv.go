@@ -0,0 +1,255 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+)
+
+// dotenvParser parses variables written in dotenv syntax. The same
+// grammar is used for files, where variables are separated by new lines,
+// and for the command line, where variables are separated by commas.
+//
+//	# comments and blank lines are skipped
+//	export NAME=value           # 'export ' is optional, inline comments too
+//	NAME="line one\nline two"   # double quotes support escapes
+//	NAME='$literal'             # single quotes are taken verbatim
+//	NAME="first line
+//	second line"                # quoted values can span lines
+//	NAME=first \
+//	second                      # a trailing backslash continues the line
+//	# content-type: text/plain
+//	NAME=aGVsbG8=               # the content type of the file held by NAME
+type dotenvParser struct {
+	input     string
+	pos       int
+	line      int
+	entry     int
+	separator byte
+	comments  bool
+	nameOnly  bool
+	// rules are checked on every variable if not nil
+	rules *LintRules
+	seen  map[string]bool
+}
+
+func newDotenvParser(input string, separator byte, nameOnly bool) *dotenvParser {
+	return &dotenvParser{
+		input:     input,
+		line:      1,
+		separator: separator,
+		// a '#' on the command line is much more likely to be part of a
+		// value than a comment
+		comments: separator == '\n',
+		nameOnly: nameOnly,
+		seen:     make(map[string]bool),
+	}
+}
+
+func (p *dotenvParser) errorf(format string, args ...interface{}) error {
+	if p.separator == '\n' {
+		return fmt.Errorf("line %d: %s", p.line, fmt.Sprintf(format, args...))
+	}
+	return fmt.Errorf("variable %d: %s", p.entry, fmt.Sprintf(format, args...))
+}
+
+func (p *dotenvParser) done() bool {
+	return p.pos >= len(p.input)
+}
+
+func (p *dotenvParser) peek() byte {
+	return p.input[p.pos]
+}
+
+func (p *dotenvParser) next() byte {
+	c := p.input[p.pos]
+	p.pos++
+	if c == '\n' {
+		p.line++
+	}
+	return c
+}
+
+func (p *dotenvParser) atEndOfEntry() bool {
+	return p.done() || p.peek() == p.separator || p.peek() == '\n'
+}
+
+func (p *dotenvParser) skipBlanks() {
+	for !p.done() && (p.peek() == ' ' || p.peek() == '\t' || p.peek() == '\r') {
+		p.next()
+	}
+}
+
+func (p *dotenvParser) skipToEndOfLine() {
+	for !p.done() && p.peek() != '\n' {
+		p.next()
+	}
+}
+
+// atComment reports whether an inline comment starts at the current
+// position. A '#' only starts a comment when it follows whitespace so
+// that values like color=#fff still work.
+func (p *dotenvParser) atComment() bool {
+	if !p.comments || p.done() || p.peek() != '#' {
+		return false
+	}
+	return p.pos == 0 || strings.IndexByte(" \t\n", p.input[p.pos-1]) >= 0
+}
+
+// contentTypeComment starts the comment giving the content type of the
+// file variable on the next line
+const contentTypeComment = "# content-type:"
+
+func (p *dotenvParser) parse() ([]Variable, error) {
+	variables := make([]Variable, 0)
+	for {
+		// skip blank lines, empty entries and full line comments
+		contentType := ""
+		for !p.done() {
+			c := p.peek()
+			if c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == p.separator {
+				p.next()
+			} else if p.atComment() {
+				start := p.pos
+				p.skipToEndOfLine()
+				comment := strings.TrimRight(p.input[start:p.pos], " \t\r")
+				if strings.HasPrefix(comment, contentTypeComment) {
+					contentType = strings.TrimSpace(strings.TrimPrefix(comment, contentTypeComment))
+				}
+			} else {
+				break
+			}
+		}
+		if p.done() {
+			return variables, nil
+		}
+		p.entry++
+		line := p.line
+		variable, err := p.parseVariable()
+		if err != nil {
+			return variables, err
+		}
+		variable.ContentType = contentType
+		if p.rules != nil {
+			if problems := p.rules.problems(variable, p.seen); len(problems) > 0 {
+				// report the line the variable starts on
+				p.line = line
+				return variables, p.errorf("%s", strings.Join(problems, "; "))
+			}
+			p.seen[variable.Name] = true
+		}
+		variables = append(variables, variable)
+	}
+}
+
+func (p *dotenvParser) parseVariable() (Variable, error) {
+	// only strip export when it is a keyword, not the start of a name
+	// like EXPORTED_VAR or export_dir
+	if strings.HasPrefix(p.input[p.pos:], "export") {
+		rest := p.input[p.pos+len("export"):]
+		if rest != "" && (rest[0] == ' ' || rest[0] == '\t') {
+			p.pos += len("export")
+			p.skipBlanks()
+		}
+	}
+
+	start := p.pos
+	for !p.atEndOfEntry() && p.peek() != '=' && !p.atComment() {
+		p.next()
+	}
+	name := strings.TrimRight(p.input[start:p.pos], " \t\r")
+	if name == "" {
+		return Variable{}, p.errorf("missing variable name")
+	}
+
+	if p.done() || p.peek() != '=' {
+		if !p.nameOnly {
+			return Variable{}, p.errorf("expected '=' after %q", name)
+		}
+		if p.atComment() {
+			p.skipToEndOfLine()
+		}
+		return Variable{Name: name}, nil
+	}
+	p.next() // consume '='
+	p.skipBlanks()
+
+	value, err := p.parseValue()
+	if err != nil {
+		return Variable{}, err
+	}
+	if p.nameOnly {
+		value = ""
+	}
+	return Variable{Name: name, Value: value}, nil
+}
+
+func (p *dotenvParser) parseValue() (string, error) {
+	if p.done() {
+		return "", nil
+	}
+	switch p.peek() {
+	case '"', '\'':
+		value, err := p.parseQuoted()
+		if err != nil {
+			return "", err
+		}
+		p.skipBlanks()
+		if p.atComment() {
+			p.skipToEndOfLine()
+		}
+		if !p.atEndOfEntry() {
+			return "", p.errorf("unexpected %q after quoted value", p.peek())
+		}
+		return value, nil
+	}
+	return p.parseUnquoted(), nil
+}
+
+func (p *dotenvParser) parseQuoted() (string, error) {
+	startLine := p.line
+	quote := p.next()
+	var value strings.Builder
+	for !p.done() {
+		c := p.next()
+		if c == quote {
+			return value.String(), nil
+		}
+		if c != '\\' || quote == '\'' || p.done() {
+			value.WriteByte(c)
+			continue
+		}
+		escaped := p.next()
+		switch escaped {
+		case 'n':
+			value.WriteByte('\n')
+		case 't':
+			value.WriteByte('\t')
+		case 'r':
+			value.WriteByte('\r')
+		case '"', '\\', '$':
+			value.WriteByte(escaped)
+		case '\n': // line continuation
+		default:
+			value.WriteByte('\\')
+			value.WriteByte(escaped)
+		}
+	}
+	p.line = startLine
+	return "", p.errorf("unterminated %c quoted value", quote)
+}
+
+func (p *dotenvParser) parseUnquoted() string {
+	var value strings.Builder
+	for !p.atEndOfEntry() && !p.atComment() {
+		c := p.next()
+		// a backslash at the end of a line continues the value on the
+		// next line
+		if c == '\\' && p.separator == '\n' && !p.done() && (p.peek() == '\n' || strings.HasPrefix(p.input[p.pos:], "\r\n")) {
+			p.skipToEndOfLine()
+			p.next()
+			continue
+		}
+		value.WriteByte(c)
+	}
+	return strings.TrimRight(value.String(), " \t\r")
+}
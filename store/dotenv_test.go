@@ -0,0 +1,80 @@
+package store
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseDotenv(t *testing.T) {
+	content := `# a comment
+export ONE=one
+EXPORTED_VAR=kept
+  DOUBLE="with \"escapes\"\tand\nnew line"   # inline comment
+SINGLE='$literal \n # not a comment'
+MULTI="first
+second"
+CONTINUED=first \
+second
+COLOR=#fff
+EMPTY=
+TRAILING=value # comment
+`
+	variables, err := newDotenvParser(content, '\n', false).parse()
+	if err != nil {
+		t.Fatalf("error parsing dotenv: %s", err)
+	}
+	expected := []Variable{
+		{Name: "ONE", Value: "one"},
+		{Name: "EXPORTED_VAR", Value: "kept"},
+		{Name: "DOUBLE", Value: "with \"escapes\"\tand\nnew line"},
+		{Name: "SINGLE", Value: `$literal \n # not a comment`},
+		{Name: "MULTI", Value: "first\nsecond"},
+		{Name: "CONTINUED", Value: "first second"},
+		{Name: "COLOR", Value: "#fff"},
+		{Name: "EMPTY", Value: ""},
+		{Name: "TRAILING", Value: "value"},
+	}
+	if !variablesEqual(variables, expected) {
+		t.Fatalf("variables don't match expected\n%v", variables)
+	}
+}
+
+func TestParseDotenvErrors(t *testing.T) {
+	tests := map[string]string{
+		"ONE=one\nTWO\n":            "line 2",
+		"ONE=\"unterminated\nTWO=2": "line 1",
+		"ONE='one' two\n":           "line 1",
+		"=value\n":                  "line 1",
+	}
+	for content, location := range tests {
+		_, err := newDotenvParser(content, '\n', false).parse()
+		if err == nil {
+			t.Fatalf("expected error parsing %q", content)
+		}
+		if !strings.HasPrefix(err.Error(), location) {
+			t.Fatalf("expected error at %s, got %s", location, err)
+		}
+	}
+}
+
+func TestParseVariablesQuotedCommas(t *testing.T) {
+	variables, err := parseVariables(`one="two,three",four=a=b,five='#six'`, false)
+	if err != nil {
+		t.Fatalf("error parsing variables: %s", err)
+	}
+	expected := []Variable{
+		{Name: "one", Value: "two,three"},
+		{Name: "four", Value: "a=b"},
+		{Name: "five", Value: "#six"},
+	}
+	if !variablesEqual(variables, expected) {
+		t.Fatalf("variables don't match expected\n%v", variables)
+	}
+}
+
+func TestParseVariablesMissingSeparator(t *testing.T) {
+	_, err := parseVariables("one=two,three", false)
+	if err == nil {
+		t.Fatalf("expected error for variable without '='")
+	}
+}
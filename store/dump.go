@@ -0,0 +1,281 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// DefaultSecretPatterns match the names of variables that are likely to
+// hold secrets
+var DefaultSecretPatterns = []string{
+	"*SECRET*",
+	"*PASSWORD*",
+	"*PASSWD*",
+	"*TOKEN*",
+	"*PRIVATE_KEY*",
+	"*API_KEY*",
+	"*CREDENTIAL*",
+}
+
+// Mask is the value of a masked secret when there is no mask key
+const Mask = "********"
+
+// maskPrefixes start the values of secrets masked with a key, and of
+// those masked with a plain hash by earlier versions
+var maskPrefixes = []string{"hmac:", "sha256:"}
+
+// IsSecret reports whether the variable name matches one of the patterns.
+// Patterns use the syntax of path.Match and are case insensitive.
+func IsSecret(name string, patterns []string) bool {
+	name = strings.ToUpper(name)
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(strings.ToUpper(pattern), name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// MaskValue replaces a secret with Mask or, if there is a key, with a
+// short HMAC of it so that changes to the secret are still visible. The
+// key keeps short secrets from being guessed from their masks.
+func MaskValue(value string, key []byte) string {
+	if value == "" {
+		return value
+	}
+	if len(key) == 0 {
+		return Mask
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(value))
+	return maskPrefixes[0] + hex.EncodeToString(mac.Sum(nil)[:8])
+}
+
+// isMasked reports whether value is the mask of a secret rather than a
+// value
+func isMasked(value string) bool {
+	if value == Mask {
+		return true
+	}
+	for _, prefix := range maskPrefixes {
+		if digest := strings.TrimPrefix(value, prefix); len(digest) == 16 && digest != value {
+			if _, err := hex.DecodeString(digest); err == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// MaskSecrets returns a copy of the item with the values of variables
+// whose names match one of the patterns, or are one of names, replaced
+// with Mask
+func (item *Item) MaskSecrets(patterns []string, names ...string) Item {
+	masked := Item{ID: item.ID, Variables: make([]Variable, len(item.Variables))}
+	for i, variable := range item.Variables {
+		if IsSecret(variable.Name, patterns) || slices.Contains(names, variable.Name) {
+			variable.Value = MaskValue(variable.Value, nil)
+		}
+		masked.Variables[i] = variable
+	}
+	return masked
+}
+
+// DumpOptions control how Dump writes items
+type DumpOptions struct {
+	// Format is the format of the files, FormatEnv or FormatYAML
+	Format string
+	// Reveal writes secrets as they are instead of masking them
+	Reveal bool
+	// SecretPatterns match the names of secrets. DefaultSecretPatterns
+	// are used if empty.
+	SecretPatterns []string
+	// Prune removes files of the format for items that don't exist
+	Prune bool
+	// MaskKey keys the HMACs secrets are masked with. Secrets are all
+	// masked with Mask if it is empty.
+	MaskKey []byte
+}
+
+// pathFromID is the inverse of idFromPath
+func pathFromID(id, extension string) (string, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) || strings.HasPrefix(id, ".") {
+		return "", fmt.Errorf("can't derive a file name from id %q", id)
+	}
+	parts := strings.SplitN(id, "__", 2)
+	for _, part := range parts {
+		if part == "" || strings.HasPrefix(part, ".") {
+			return "", fmt.Errorf("can't derive a file name from id %q", id)
+		}
+	}
+	return filepath.Join(parts...) + extension, nil
+}
+
+// Dump writes every item whose id starts with prefix to the directory
+// dir in the layout read by ReadConfigDir. Variables are sorted by name
+// so that dumping the same items always produces the same files. It
+// returns the number of items written.
+func Dump(dir, prefix string, options DumpOptions) (int, error) {
+	return defaultClient.Dump(context.Background(), dir, prefix, options)
+}
+
+// Dump writes every item whose id starts with prefix to the directory
+// dir in the layout read by ReadConfigDir and returns the number of
+// items written
+func (c *Client) Dump(ctx context.Context, dir, prefix string, options DumpOptions) (int, error) {
+	extension := ".env"
+	switch options.Format {
+	case FormatEnv, "":
+	case FormatYAML:
+		extension = ".yaml"
+	default:
+		return 0, validationErrorf("can't dump to format %s", options.Format)
+	}
+	patterns := options.SecretPatterns
+	if len(patterns) == 0 {
+		patterns = DefaultSecretPatterns
+	}
+
+	written := make(map[string]bool)
+	// the secrets declared by the schemas of applications, by application
+	schemaSecrets := make(map[string][]string)
+	err := c.scan(ctx, prefix, func(item Item) error {
+		if isSchemaID(item.ID) {
+			return nil
+		}
+		relativePath, err := pathFromID(item.ID, extension)
+		if err != nil {
+			return err
+		}
+		app := AppFromID(item.ID)
+		secrets, cached := schemaSecrets[app]
+		if !cached && !options.Reveal {
+			schema, _, err := c.schemaFor(ctx, item.ID)
+			if err != nil {
+				return err
+			}
+			secrets = schema.Secrets()
+			schemaSecrets[app] = secrets
+		}
+		vars := append([]Variable(nil), item.Variables...)
+		sort.SliceStable(vars, func(i, j int) bool { return vars[i].Name < vars[j].Name })
+		for i := range vars {
+			if !options.Reveal && (IsSecret(vars[i].Name, patterns) || slices.Contains(secrets, vars[i].Name)) {
+				vars[i].Value = MaskValue(vars[i].Value, options.MaskKey)
+			}
+		}
+
+		var content []byte
+		if extension == ".yaml" {
+			content, err = formatYAML(vars)
+		} else {
+			content = formatEnv(vars)
+		}
+		if err != nil {
+			return err
+		}
+		filePath := filepath.Join(dir, relativePath)
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			return err
+		}
+		if err := WritePrivateFile(filePath, content); err != nil {
+			return err
+		}
+		written[filePath] = true
+		return nil
+	})
+	if err != nil || !options.Prune {
+		return len(written), err
+	}
+	return len(written), pruneDump(dir, prefix, extension, written)
+}
+
+// pruneDump removes files with extension in dir holding items whose ids
+// start with prefix that weren't just written
+func pruneDump(dir, prefix, extension string, written map[string]bool) error {
+	return filepath.Walk(dir, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || filepath.Ext(filePath) != extension || written[filePath] {
+			return nil
+		}
+		relativePath, err := filepath.Rel(dir, filePath)
+		if err != nil {
+			return err
+		}
+		id, err := idFromPath(relativePath)
+		if err != nil || !strings.HasPrefix(id, prefix) {
+			return nil
+		}
+		return os.Remove(filePath)
+	})
+}
+
+// formatEnv writes variables in the dotenv syntax read by the parser,
+// quoting values when needed. The content types of file variables are
+// written in comments before them.
+func formatEnv(vars []Variable) []byte {
+	var buffer bytes.Buffer
+	for _, variable := range vars {
+		if variable.IsFile() {
+			fmt.Fprintf(&buffer, "%s %s\n", contentTypeComment, variable.ContentType)
+		}
+		fmt.Fprintf(&buffer, "%s=%s\n", variable.Name, quoteEnvValue(variable.Value))
+	}
+	return buffer.Bytes()
+}
+
+func quoteEnvValue(value string) string {
+	if !strings.ContainsAny(value, " \t\r\n\"'#\\$,=`") {
+		return value
+	}
+	replacer := strings.NewReplacer(
+		`\`, `\\`,
+		`"`, `\"`,
+		`$`, `\$`,
+		"\n", `\n`,
+		"\r", `\r`,
+		"\t", `\t`,
+	)
+	return `"` + replacer.Replace(value) + `"`
+}
+
+// yamlFileVariable is the yaml of the value of a file variable
+type yamlFileVariable struct {
+	Value       string `yaml:"value"`
+	ContentType string `yaml:"content_type"`
+}
+
+// formatYAML writes variables as a map of names to values. File
+// variables map to their value and content type.
+func formatYAML(vars []Variable) ([]byte, error) {
+	mapping := &yaml.Node{Kind: yaml.MappingNode}
+	for _, variable := range vars {
+		var value interface{} = variable.Value
+		if variable.IsFile() {
+			value = yamlFileVariable{Value: variable.Value, ContentType: variable.ContentType}
+		}
+		var nameNode, valueNode yaml.Node
+		if err := nameNode.Encode(variable.Name); err != nil {
+			return nil, err
+		}
+		if err := valueNode.Encode(value); err != nil {
+			return nil, err
+		}
+		mapping.Content = append(mapping.Content, &nameNode, &valueNode)
+	}
+	return yaml.Marshal(mapping)
+}
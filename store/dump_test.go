@@ -0,0 +1,136 @@
+package store
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func TestIsSecret(t *testing.T) {
+	if !IsSecret("db_password", DefaultSecretPatterns) || !IsSecret("GITHUB_TOKEN", DefaultSecretPatterns) {
+		t.Fatalf("expected password and token to be secrets")
+	}
+	if IsSecret("DB_HOST", DefaultSecretPatterns) {
+		t.Fatalf("expected DB_HOST not to be a secret")
+	}
+}
+
+func TestDump(t *testing.T) {
+	mock := mockDynamoDBClient{items: map[string]map[string]*dynamodb.AttributeValue{}}
+	SetDB(mock)
+	err := SaveItem(CreateItem("app__prod", []Variable{
+		{Name: "quoted", Value: "has \"quotes\" and\nnew line"},
+		{Name: "DB_PASSWORD", Value: "hunter2"},
+		{Name: "one", Value: "two"},
+	}))
+	if err != nil {
+		t.Fatalf("error %s", err)
+	}
+	if err := Save("other", "seven=eight"); err != nil {
+		t.Fatalf("error %s", err)
+	}
+	dir, err := ioutil.TempDir("", "envi")
+	if err != nil {
+		t.Fatalf("error creating temp dir %s", err)
+	}
+	defer os.RemoveAll(dir)
+	stale := filepath.Join(dir, "app", "old.env")
+	os.MkdirAll(filepath.Dir(stale), 0755)
+	ioutil.WriteFile(stale, []byte("one=two\n"), 0644)
+	// an earlier dump that others can read
+	ioutil.WriteFile(filepath.Join(dir, "app", "prod.env"), []byte("one=two\n"), 0644)
+
+	count, err := Dump(dir, "", DumpOptions{Prune: true})
+	if err != nil {
+		t.Fatalf("error dumping %s", err)
+	}
+	if count != 2 {
+		t.Fatalf("expected two dumped items, got %d", count)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Fatalf("expected stale file to be pruned")
+	}
+	content, err := ioutil.ReadFile(filepath.Join(dir, "app", "prod.env"))
+	if err != nil {
+		t.Fatalf("error reading dump %s", err)
+	}
+	expected := "DB_PASSWORD=" + Mask + "\none=two\nquoted=\"has \\\"quotes\\\" and\\nnew line\"\n"
+	if string(content) != expected {
+		t.Fatalf("dump doesn't match expected\n%s", content)
+	}
+	if info, err := os.Stat(filepath.Join(dir, "app", "prod.env")); err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("expected the dump to only be readable by its owner %v %v", info, err)
+	}
+
+	// the dump can be read back
+	items, err := ReadConfigDir(dir)
+	if err != nil {
+		t.Fatalf("error reading dump %s", err)
+	}
+	if len(items) != 2 || items[0].Variables[2].Value != "has \"quotes\" and\nnew line" {
+		t.Fatalf("dump didn't round trip %v", items)
+	}
+	// syncing the dump back doesn't overwrite the masked secret
+	changes, err := PlanSync(items, false, "")
+	if err != nil {
+		t.Fatalf("error planning %s", err)
+	}
+	if len(changes) != 0 {
+		t.Fatalf("expected no changes syncing a dump, got %v", changes)
+	}
+}
+
+func TestMaskValue(t *testing.T) {
+	key := []byte("key")
+	if MaskValue("hunter2", nil) != Mask || MaskValue("", nil) != "" {
+		t.Fatalf("expected a constant mask without a key")
+	}
+	masked := MaskValue("hunter2", key)
+	if masked == MaskValue("hunter3", key) || masked == MaskValue("hunter2", []byte("other")) {
+		t.Fatalf("expected masks to depend on the value and the key")
+	}
+	for _, value := range []string{Mask, masked, "sha256:9f86d081884c7d65"} {
+		if !isMasked(value) {
+			t.Fatalf("expected %s to be masked", value)
+		}
+	}
+	for _, value := range []string{"hunter2", "hmac:short", "sha256:not-hex-at-all!"} {
+		if isMasked(value) {
+			t.Fatalf("expected %s not to be masked", value)
+		}
+	}
+}
+
+func TestDumpYAML(t *testing.T) {
+	mock := mockDynamoDBClient{items: map[string]map[string]*dynamodb.AttributeValue{}}
+	SetDB(mock)
+	if err := Save("app__prod", "port=8080,API_TOKEN=abc"); err != nil {
+		t.Fatalf("error %s", err)
+	}
+	dir, err := ioutil.TempDir("", "envi")
+	if err != nil {
+		t.Fatalf("error creating temp dir %s", err)
+	}
+	defer os.RemoveAll(dir)
+	if _, err := Dump(dir, "", DumpOptions{Format: FormatYAML, Reveal: true}); err != nil {
+		t.Fatalf("error dumping %s", err)
+	}
+	content, err := ioutil.ReadFile(filepath.Join(dir, "app", "prod.yaml"))
+	if err != nil {
+		t.Fatalf("error reading dump %s", err)
+	}
+	if string(content) != "API_TOKEN: abc\nport: \"8080\"\n" {
+		t.Fatalf("dump doesn't match expected\n%s", content)
+	}
+}
+
+func TestPathFromID(t *testing.T) {
+	for _, id := range []string{"../etc__passwd", "app__../x", "a/b__c", "__prod"} {
+		if _, err := pathFromID(id, ".env"); err == nil {
+			t.Fatalf("expected error for id %s", id)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// services of the arns, in any partition, that ECS can resolve itself at
+// container start. Variables holding one of these are rendered as
+// secrets instead of plain environment variables.
+var ecsSecretServices = []string{
+	"ssm",
+	"secretsmanager",
+}
+
+// ecsKeyValue is the format of an entry in a container definition's
+// environment array
+type ecsKeyValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// ecsSecret is the format of an entry in a container definition's
+// secrets array
+type ecsSecret struct {
+	Name      string `json:"name"`
+	ValueFrom string `json:"valueFrom"`
+}
+
+// isECSSecret reports whether value is an arn, e.g.
+// arn:aws-us-gov:ssm:..., of a service in ecsSecretServices
+func isECSSecret(value string) bool {
+	parts := strings.SplitN(value, ":", 4)
+	if len(parts) != 4 || parts[0] != "arn" || parts[1] == "" {
+		return false
+	}
+	for _, service := range ecsSecretServices {
+		if parts[2] == service {
+			return true
+		}
+	}
+	return false
+}
+
+// RenderECSTaskDefinition replaces the environment and secrets of the
+// container named 'container' in the task definition 'taskDef' with the
+// variables in the item. Variables whose values are SSM parameter or
+// Secrets Manager arns are rendered as secrets. 'taskDef' can be either
+// the input of register-task-definition or the output of
+// describe-task-definition. If 'container' is empty the task definition
+// must have exactly one container.
+func (item *Item) RenderECSTaskDefinition(taskDef []byte, container string) ([]byte, error) {
+	var document map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(taskDef))
+	decoder.UseNumber() // don't turn cpu, memory, ports etc into floats
+	if err := decoder.Decode(&document); err != nil {
+		return nil, fmt.Errorf("error parsing task definition: %s", err)
+	}
+
+	definition := document
+	// describe-task-definition wraps the definition
+	if wrapped, ok := document["taskDefinition"].(map[string]interface{}); ok {
+		definition = wrapped
+	}
+	containers, ok := definition["containerDefinitions"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("task definition has no containerDefinitions")
+	}
+
+	containerDefinition, err := findContainer(containers, container)
+	if err != nil {
+		return nil, err
+	}
+
+	environment := make([]ecsKeyValue, 0, len(item.Variables))
+	secrets := make([]ecsSecret, 0)
+	for _, variable := range item.Variables {
+		if isECSSecret(variable.Value) {
+			secrets = append(secrets, ecsSecret{Name: variable.Name, ValueFrom: variable.Value})
+		} else {
+			environment = append(environment, ecsKeyValue{Name: variable.Name, Value: variable.Value})
+		}
+	}
+	containerDefinition["environment"] = environment
+	if len(secrets) > 0 {
+		containerDefinition["secrets"] = secrets
+	} else {
+		delete(containerDefinition, "secrets")
+	}
+
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "   ")
+	if err := encoder.Encode(document); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func findContainer(containers []interface{}, name string) (map[string]interface{}, error) {
+	if name == "" {
+		if len(containers) != 1 {
+			return nil, fmt.Errorf("task definition has %d containers; must provide container name", len(containers))
+		}
+		containerDefinition, ok := containers[0].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("container definition is not an object")
+		}
+		return containerDefinition, nil
+	}
+	for _, c := range containers {
+		containerDefinition, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if containerDefinition["name"] == name {
+			return containerDefinition, nil
+		}
+	}
+	return nil, fmt.Errorf("container %s not found in task definition", name)
+}
@@ -0,0 +1,113 @@
+package store
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+var testTaskDefinition = `{
+	"family": "app",
+	"cpu": "256",
+	"containerDefinitions": [
+		{
+			"name": "web",
+			"memory": 512,
+			"environment": [{"name": "old", "value": "gone"}],
+			"secrets": [{"name": "old_secret", "valueFrom": "arn:aws:ssm:us-east-1:123456789012:parameter/old"}]
+		},
+		{
+			"name": "sidecar",
+			"environment": [{"name": "untouched", "value": "yes"}]
+		}
+	]
+}`
+
+type testContainerDefinition struct {
+	Name        string        `json:"name"`
+	Memory      int           `json:"memory"`
+	Environment []ecsKeyValue `json:"environment"`
+	Secrets     []ecsSecret   `json:"secrets"`
+}
+
+type testTaskDefinitionDocument struct {
+	Family               string                    `json:"family"`
+	ContainerDefinitions []testContainerDefinition `json:"containerDefinitions"`
+}
+
+func TestRenderECSTaskDefinition(t *testing.T) {
+	item := Item{
+		ID: "app__prod",
+		Variables: []Variable{
+			{Name: "one", Value: "two"},
+			{Name: "db_password", Value: "arn:aws:secretsmanager:us-east-1:123456789012:secret:db"},
+		},
+	}
+	rendered, err := item.RenderECSTaskDefinition([]byte(testTaskDefinition), "web")
+	if err != nil {
+		t.Fatalf("error rendering task definition: %s", err)
+	}
+	var document testTaskDefinitionDocument
+	if err := json.Unmarshal(rendered, &document); err != nil {
+		t.Fatalf("rendered task definition is not valid json: %s", err)
+	}
+	if document.Family != "app" {
+		t.Fatalf("expected unrelated fields to be kept")
+	}
+	web := document.ContainerDefinitions[0]
+	if web.Memory != 512 {
+		t.Fatalf("expected memory to be kept as a number")
+	}
+	if len(web.Environment) != 1 || web.Environment[0].Name != "one" || web.Environment[0].Value != "two" {
+		t.Fatalf("environment not replaced with variables %v", web.Environment)
+	}
+	if len(web.Secrets) != 1 || web.Secrets[0].Name != "db_password" {
+		t.Fatalf("secrets not replaced with arn variables %v", web.Secrets)
+	}
+	sidecar := document.ContainerDefinitions[1]
+	if len(sidecar.Environment) != 1 || sidecar.Environment[0].Name != "untouched" {
+		t.Fatalf("expected other containers to be untouched")
+	}
+}
+
+func TestIsECSSecret(t *testing.T) {
+	for _, value := range []string{
+		"arn:aws:ssm:us-east-1:123456789012:parameter/db",
+		"arn:aws-us-gov:secretsmanager:us-gov-west-1:123456789012:secret:db",
+		"arn:aws-cn:ssm:cn-north-1:123456789012:parameter/db",
+	} {
+		if !isECSSecret(value) {
+			t.Fatalf("expected %s to be a secret", value)
+		}
+	}
+	for _, value := range []string{"two", "arn:aws:s3:::bucket", "arn::ssm:us-east-1", "xarn:aws:ssm:us-east-1:1:parameter/db"} {
+		if isECSSecret(value) {
+			t.Fatalf("expected %s not to be a secret", value)
+		}
+	}
+}
+
+func TestRenderECSTaskDefinitionDescribeOutput(t *testing.T) {
+	wrapped := `{"taskDefinition": {"containerDefinitions": [{"name": "web"}]}}`
+	rendered, err := testItemOne.RenderECSTaskDefinition([]byte(wrapped), "")
+	if err != nil {
+		t.Fatalf("error rendering task definition: %s", err)
+	}
+	if !strings.Contains(string(rendered), `"name": "three"`) {
+		t.Fatalf("expected variables in rendered output\n%s", rendered)
+	}
+	if strings.Contains(string(rendered), "secrets") {
+		t.Fatalf("expected no secrets in rendered output\n%s", rendered)
+	}
+}
+
+func TestRenderECSTaskDefinitionContainerRequired(t *testing.T) {
+	_, err := testItemOne.RenderECSTaskDefinition([]byte(testTaskDefinition), "")
+	if err == nil {
+		t.Fatalf("expected error when container is ambiguous")
+	}
+	_, err = testItemOne.RenderECSTaskDefinition([]byte(testTaskDefinition), "missing")
+	if err == nil {
+		t.Fatalf("expected error when container doesn't exist")
+	}
+}
@@ -0,0 +1,62 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Kinds of errors returned by the store. Use errors.Is to check the kind
+// of an error.
+var (
+	// ErrNotFound means a config or variable doesn't exist
+	ErrNotFound = errors.New("not found")
+	// ErrConflict means a write conflicts with what is stored, e.g. the
+	// new id of a rename already exists
+	ErrConflict = errors.New("conflict")
+	// ErrValidation means the input is invalid, e.g. a file can't be
+	// parsed
+	ErrValidation = errors.New("invalid input")
+)
+
+// Error is an error of one of the kinds ErrNotFound, ErrConflict or
+// ErrValidation
+type Error struct {
+	Kind error
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether the error is of the kind target
+func (e *Error) Is(target error) bool {
+	return target == e.Kind
+}
+
+func notFoundErrorf(format string, args ...interface{}) error {
+	return &Error{Kind: ErrNotFound, Err: fmt.Errorf(format, args...)}
+}
+
+func conflictErrorf(format string, args ...interface{}) error {
+	return &Error{Kind: ErrConflict, Err: fmt.Errorf(format, args...)}
+}
+
+func validationErrorf(format string, args ...interface{}) error {
+	return &Error{Kind: ErrValidation, Err: fmt.Errorf(format, args...)}
+}
+
+// validationError marks err as a validation error unless it already has
+// a kind
+func validationError(err error) error {
+	var kinded *Error
+	if err == nil || errors.As(err, &kinded) {
+		return err
+	}
+	return &Error{Kind: ErrValidation, Err: err}
+}
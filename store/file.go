@@ -0,0 +1,113 @@
+package store
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FileSuffix is appended to the name of a file variable to name the
+// variable holding the path of its materialized file
+const FileSuffix = "_FILE"
+
+// FileVariable returns a variable holding the contents of a file, e.g. a
+// certificate or a keystore. The value is the base64 encoded contents so
+// it can be stored, printed and exported like any other value.
+func FileVariable(name string, content []byte, contentType string) Variable {
+	if contentType == "" {
+		contentType = http.DetectContentType(content)
+	}
+	return Variable{
+		Name:        name,
+		Value:       base64.StdEncoding.EncodeToString(content),
+		ContentType: contentType,
+	}
+}
+
+// ReadFileVariable returns a variable holding the contents of the file
+// at path. The content type is guessed from the extension and contents
+// of the file if it is empty.
+func ReadFileVariable(name, path, contentType string) (Variable, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Variable{}, err
+	}
+	if contentType == "" {
+		contentType = mime.TypeByExtension(filepath.Ext(path))
+	}
+	return FileVariable(name, content, contentType), nil
+}
+
+// IsFile reports whether the variable holds the contents of a file
+func (variable *Variable) IsFile() bool {
+	return variable.ContentType != ""
+}
+
+// FileContent returns the contents of the file held by a file variable
+func (variable *Variable) FileContent() ([]byte, error) {
+	content, err := base64.StdEncoding.DecodeString(variable.Value)
+	if err != nil {
+		return nil, validationErrorf("%s isn't base64 encoded: %s", variable.Name, err)
+	}
+	return content, nil
+}
+
+// WritePrivateFile writes content to the file at path so that only the
+// owner can read it. The content is written to a temporary file that
+// then replaces the file at path, so an existing file is neither left
+// half written nor keeps a mode that lets others read it.
+func WritePrivateFile(path string, content []byte) error {
+	file, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".")
+	if err != nil {
+		return err
+	}
+	// TempFile creates files only the owner can read
+	_, err = file.Write(content)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), path)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+	}
+	return err
+}
+
+// Materialize writes the contents of every file variable of the item to
+// a file named after the variable in dir, which is created if needed,
+// and returns the variables of the item with a NAME_FILE variable
+// holding the path of the file added after each file variable. Only the
+// owner can read the files. Names that aren't a single path element are
+// rejected so that no file is written outside of dir.
+func (item *Item) Materialize(dir string) ([]Variable, error) {
+	vars := make([]Variable, 0, len(item.Variables))
+	for _, variable := range item.Variables {
+		vars = append(vars, variable)
+		if !variable.IsFile() {
+			continue
+		}
+		// names of imported or unlinted variables may be anything
+		if variable.Name == "" || variable.Name == "." || variable.Name == ".." || strings.ContainsAny(variable.Name, `/\`) {
+			return nil, validationErrorf("%s can't be the name of a file", variable.Name)
+		}
+		content, err := variable.FileContent()
+		if err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return nil, err
+		}
+		path := filepath.Join(dir, variable.Name)
+		if err := WritePrivateFile(path, content); err != nil {
+			return nil, err
+		}
+		vars = append(vars, Variable{Name: variable.Name + FileSuffix, Value: path})
+	}
+	return vars, nil
+}
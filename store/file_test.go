@@ -0,0 +1,100 @@
+package store
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func TestReadFileVariable(t *testing.T) {
+	dir, err := ioutil.TempDir("", "envi-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "cert.pem")
+	content := []byte("-----BEGIN CERTIFICATE-----\n\x00\x01binary\n-----END CERTIFICATE-----\n")
+	if err := ioutil.WriteFile(path, content, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	variable, err := ReadFileVariable("TLS_CERT", path, "application/x-pem-file")
+	if err != nil {
+		t.Fatalf("error reading file variable %s", err)
+	}
+	if !variable.IsFile() || variable.ContentType != "application/x-pem-file" {
+		t.Fatalf("unexpected file variable %v", variable)
+	}
+	if _, err := ReadFileVariable("TLS_CERT", filepath.Join(dir, "missing"), ""); err == nil {
+		t.Fatalf("expected error reading a missing file")
+	}
+	if guessed := FileVariable("KEYSTORE", []byte{0, 1, 2}, ""); guessed.ContentType != "application/octet-stream" {
+		t.Fatalf("unexpected guessed content type %s", guessed.ContentType)
+	}
+
+	// the content type is stored and merged by update
+	mock := mockDynamoDBClient{items: map[string]map[string]*dynamodb.AttributeValue{}}
+	SetDB(mock)
+	if err := Save("app__prod", "one=two"); err != nil {
+		t.Fatalf("error saving %s", err)
+	}
+	if err := UpdateItem(CreateItem("app__prod", []Variable{variable}), false); err != nil {
+		t.Fatalf("error updating %s", err)
+	}
+	item, err := Get("app__prod")
+	if err != nil {
+		t.Fatalf("error getting %s", err)
+	}
+
+	vars, err := item.Materialize(filepath.Join(dir, "files"))
+	if err != nil {
+		t.Fatalf("error materializing %s", err)
+	}
+	if len(vars) != 3 || vars[2].Name != "TLS_CERT_FILE" {
+		t.Fatalf("expected a path variable after the file variable %v", vars)
+	}
+	written, err := ioutil.ReadFile(vars[2].Value)
+	if err != nil || string(written) != string(content) {
+		t.Fatalf("unexpected materialized file %q %v", written, err)
+	}
+	if info, err := os.Stat(vars[2].Value); err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("expected file to only be readable by its owner %v %v", info, err)
+	}
+
+	for _, name := range []string{"../../escaped", "a/b", `a\b`, ".."} {
+		unsafe := Item{ID: "app__prod", Variables: []Variable{FileVariable(name, content, "")}}
+		if _, err := unsafe.Materialize(filepath.Join(dir, "files")); !errors.Is(err, ErrValidation) {
+			t.Fatalf("expected a validation error materializing %s, got %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "escaped")); !os.IsNotExist(err) {
+		t.Fatalf("expected no file outside of the files dir")
+	}
+}
+
+func TestWritePrivateFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "taskdef.json")
+	if err := ioutil.WriteFile(path, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := WritePrivateFile(path, []byte("new")); err != nil {
+		t.Fatalf("error writing %s", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Fatalf("expected only the owner to be able to read the file, got %s", info.Mode())
+	}
+	if content, err := ioutil.ReadFile(path); err != nil || string(content) != "new" {
+		t.Fatalf("unexpected content %q %v", content, err)
+	}
+	if files, _ := ioutil.ReadDir(filepath.Dir(path)); len(files) != 1 {
+		t.Fatalf("expected the temporary file to be gone, got %d files", len(files))
+	}
+}
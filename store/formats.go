@@ -0,0 +1,289 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// Formats of files that variables can be read from
+const (
+	// FormatEnv is a dotenv or shell file exporting variables
+	FormatEnv = "env"
+	// FormatJSON is a json object of names to values
+	FormatJSON = "json"
+	// FormatECS is the array of name and value objects used in ecs task
+	// definitions and printed by get -o json
+	FormatECS = "ecs"
+	// FormatYAML is a yaml map of names to values
+	FormatYAML = "yaml"
+	// FormatDocker is the file format of docker run --env-file
+	FormatDocker = "docker"
+)
+
+// maxLineSize is the longest line of a variables file, as long as the
+// largest item that can be stored, e.g. a value holding a certificate
+const maxLineSize = maxChunks * chunkSize
+
+// newLineScanner returns a scanner of the lines of content that allows
+// lines of up to maxLineSize
+func newLineScanner(content []byte) *bufio.Scanner {
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	return scanner
+}
+
+// detectFormat guesses the format of a variables file from its name and
+// content
+func detectFormat(fileName string, content []byte) string {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".yaml", ".yml":
+		return FormatYAML
+	}
+	trimmed := bytes.TrimSpace(content)
+	if len(trimmed) > 0 {
+		switch trimmed[0] {
+		case '[':
+			return FormatECS
+		case '{':
+			return FormatJSON
+		}
+	}
+	return FormatEnv
+}
+
+// parseVariablesFromFile reads variables from the file fileName, or stdin
+// if fileName is "-". If format is empty it is detected from the file.
+func parseVariablesFromFile(fileName, format string, nameOnly bool) ([]Variable, error) {
+	return readVariablesFile(fileName, format, nameOnly, parseRules(nameOnly))
+}
+
+// readVariablesFile is parseVariablesFromFile checking the variables
+// against rules, if they aren't nil, instead of the default rules
+func readVariablesFile(fileName, format string, nameOnly bool, rules *LintRules) ([]Variable, error) {
+	var reader io.Reader = os.Stdin
+	if fileName != "-" {
+		file, err := os.Open(fileName)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		reader = file
+	}
+	return readVariables(reader, fileName, format, nameOnly, rules)
+}
+
+func parseVariablesFromReader(reader io.Reader, fileName, format string, nameOnly bool) ([]Variable, error) {
+	return readVariables(reader, fileName, format, nameOnly, parseRules(nameOnly))
+}
+
+func readVariables(reader io.Reader, fileName, format string, nameOnly bool, rules *LintRules) ([]Variable, error) {
+	content, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	if format == "" {
+		format = detectFormat(fileName, content)
+	}
+
+	var variables []Variable
+	// the dotenv parser checks the lint rules itself to report the lines
+	// of the problems
+	linted := false
+	switch strings.ToLower(format) {
+	case FormatEnv, "sh", "dotenv":
+		variables, err = parseLines(newLineScanner(content), nameOnly, rules)
+		linted = true
+	case FormatJSON:
+		variables, err = parseJSONObject(content)
+	case FormatECS:
+		variables, err = parseECSArray(content)
+	case FormatYAML, "yml":
+		variables, err = parseYAMLMap(content)
+	case FormatDocker:
+		variables, err = parseDockerEnvFile(content, nameOnly)
+	default:
+		return nil, validationErrorf("unknown format %s", format)
+	}
+	if err != nil {
+		return nil, validationError(err)
+	}
+	if rules != nil && !linted {
+		if err := lintError(rules.Check(variables)); err != nil {
+			return nil, err
+		}
+	}
+	if nameOnly {
+		for i := range variables {
+			variables[i].Value = ""
+		}
+	}
+	return variables, nil
+}
+
+// parseJSONObject parses a json object of names to values keeping the
+// order of the names. Values that aren't strings are kept as json.
+func parseJSONObject(content []byte) ([]Variable, error) {
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '{' {
+		return nil, fmt.Errorf("expected a json object")
+	}
+
+	variables := make([]Variable, 0)
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		name := token.(string) // keys of objects are always strings
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			return nil, fmt.Errorf("error parsing value of %s: %s", name, err)
+		}
+		var value string
+		switch {
+		case string(raw) == "null":
+		case raw[0] == '"':
+			if err := json.Unmarshal(raw, &value); err != nil {
+				return nil, err
+			}
+		default:
+			var compacted bytes.Buffer
+			if err := json.Compact(&compacted, raw); err != nil {
+				return nil, err
+			}
+			value = compacted.String()
+		}
+		variables = append(variables, Variable{Name: name, Value: value})
+	}
+	return variables, nil
+}
+
+// parseECSArray parses the array of names and values printed by get -o
+// json, which is also the format of a container definition's environment
+func parseECSArray(content []byte) ([]Variable, error) {
+	var variables []Variable
+	if err := json.Unmarshal(content, &variables); err != nil {
+		return nil, err
+	}
+	for i, variable := range variables {
+		if variable.Name == "" {
+			return nil, fmt.Errorf("element %d is missing a name", i)
+		}
+	}
+	if variables == nil {
+		variables = []Variable{}
+	}
+	return variables, nil
+}
+
+func parseYAMLMap(content []byte) ([]Variable, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return nil, err
+	}
+	if len(document.Content) == 0 {
+		// an empty file
+		return []Variable{}, nil
+	}
+	return variablesFromMapping(document.Content[0])
+}
+
+// variablesFromMapping converts a yaml map of names to scalar values
+// into variables keeping the order of the names. Values are taken as
+// written, so 1.10 stays 1.10 rather than becoming the number 1.1. File
+// variables map to a map of their value and content_type.
+func variablesFromMapping(node *yaml.Node) ([]Variable, error) {
+	if node.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("line %d: expected a map of names to values", node.Line)
+	}
+	variables := make([]Variable, 0, len(node.Content)/2)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		name := node.Content[i].Value
+		value := node.Content[i+1]
+		if value.Kind == yaml.AliasNode {
+			value = value.Alias
+		}
+		if value.Kind == yaml.MappingNode {
+			variable, err := fileVariableFromMapping(name, value)
+			if err != nil {
+				return nil, err
+			}
+			variables = append(variables, variable)
+			continue
+		}
+		if value.Kind != yaml.ScalarNode {
+			return nil, fmt.Errorf("line %d: value of %s must be a string, number or boolean", value.Line, name)
+		}
+		variable := Variable{Name: name}
+		if value.Tag != "!!null" {
+			variable.Value = value.Value
+		}
+		variables = append(variables, variable)
+	}
+	return variables, nil
+}
+
+// fileVariableFromMapping converts a yaml map of the value and
+// content_type of a file variable into the variable
+func fileVariableFromMapping(name string, node *yaml.Node) (Variable, error) {
+	variable := Variable{Name: name}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i].Value, node.Content[i+1]
+		if value.Kind != yaml.ScalarNode {
+			return variable, fmt.Errorf("line %d: %s of %s must be a string", value.Line, key, name)
+		}
+		switch key {
+		case "value":
+			variable.Value = value.Value
+		case "content_type":
+			variable.ContentType = value.Value
+		default:
+			return variable, fmt.Errorf("line %d: %s has unknown field %s", node.Content[i].Line, name, key)
+		}
+	}
+	if variable.ContentType == "" {
+		return variable, fmt.Errorf("line %d: %s is missing a content_type", node.Line, name)
+	}
+	return variable, nil
+}
+
+// parseDockerEnvFile parses the format of docker run --env-file. Values
+// are taken verbatim and a name without a value takes its value from the
+// current environment, the same as docker does.
+func parseDockerEnvFile(content []byte, nameOnly bool) ([]Variable, error) {
+	variables := make([]Variable, 0)
+	scanner := newLineScanner(content)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimLeft(scanner.Text(), " \t")
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		name := parts[0]
+		if name == "" {
+			return variables, fmt.Errorf("line %d: missing variable name", lineNumber)
+		}
+		if len(parts) == 2 {
+			variables = append(variables, Variable{Name: name, Value: parts[1]})
+		} else if value, ok := os.LookupEnv(name); ok || nameOnly {
+			variables = append(variables, Variable{Name: name, Value: value})
+		}
+	}
+	return variables, scanner.Err()
+}
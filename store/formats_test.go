@@ -0,0 +1,151 @@
+package store
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		fileName string
+		content  string
+		format   string
+	}{
+		{"config.yml", "one: two", FormatYAML},
+		{"-", `  [{"name": "one", "value": "two"}]`, FormatECS},
+		{"config.json", `{"one": "two"}`, FormatJSON},
+		{"prod.env", "one=two", FormatEnv},
+	}
+	for _, test := range tests {
+		format := detectFormat(test.fileName, []byte(test.content))
+		if format != test.format {
+			t.Fatalf("expected %s to be detected as %s, got %s", test.fileName, test.format, format)
+		}
+	}
+}
+
+func TestParseFormats(t *testing.T) {
+	tests := map[string]string{
+		FormatJSON: `{"one": "two", "three": 4, "five": null}`,
+		FormatECS: `[
+			{"name": "one", "value": "two"},
+			{"name": "three", "value": "4"},
+			{"name": "five", "value": ""}
+		]`,
+		FormatYAML:   "one: two\nthree: 4\nfive:\n",
+		FormatDocker: "# comment\none=two\nthree=4\nfive=\nENVI_TEST_UNSET\n",
+	}
+	expected := []Variable{
+		{Name: "one", Value: "two"},
+		{Name: "three", Value: "4"},
+		{Name: "five", Value: ""},
+	}
+	for format, content := range tests {
+		variables, err := parseVariablesFromReader(strings.NewReader(content), "-", format, false)
+		if err != nil {
+			t.Fatalf("error parsing %s: %s", format, err)
+		}
+		if !variablesEqual(variables, expected) {
+			t.Fatalf("%s variables don't match expected\n%v", format, variables)
+		}
+	}
+}
+
+func TestFileVariableRoundTrip(t *testing.T) {
+	vars := []Variable{
+		{Name: "ONE", Value: "two"},
+		{Name: "VERSION", Value: "1.10"},
+		FileVariable("TLS_CERT", []byte("-----BEGIN CERTIFICATE-----\n"), "application/x-pem-file"),
+	}
+	// what get -o json prints
+	printed, err := json.Marshal(vars)
+	if err != nil {
+		t.Fatalf("error marshaling %s", err)
+	}
+	dumped, err := formatYAML(vars)
+	if err != nil {
+		t.Fatalf("error formatting yaml %s", err)
+	}
+	tests := map[string][]byte{
+		FormatECS:  printed,
+		FormatEnv:  formatEnv(vars),
+		FormatYAML: dumped,
+	}
+	for format, content := range tests {
+		variables, err := parseVariablesFromReader(strings.NewReader(string(content)), "-", format, false)
+		if err != nil {
+			t.Fatalf("error parsing %s: %s", format, err)
+		}
+		if !variablesEqual(variables, vars) {
+			t.Fatalf("%s variables don't match expected\n%v", format, variables)
+		}
+	}
+
+	changed := append([]Variable(nil), vars...)
+	changed[2].ContentType = "text/plain"
+	if _, updated, _, _ := diffVariables(vars, changed, false); len(updated) != 1 || updated[0].ContentType != "text/plain" {
+		t.Fatalf("expected a changed content type to update the variable %v", updated)
+	}
+}
+
+func TestParseLongLines(t *testing.T) {
+	long := strings.Repeat("A", 512*1024)
+	for _, format := range []string{FormatEnv, FormatDocker} {
+		variables, err := parseVariablesFromReader(strings.NewReader("one=two\nCERT="+long+"\n"), "-", format, false)
+		if err != nil {
+			t.Fatalf("error parsing %s: %s", format, err)
+		}
+		if len(variables) != 2 || variables[1].Value != long {
+			t.Fatalf("%s variables don't match expected", format)
+		}
+	}
+}
+
+func TestParseYAMLKeepsScalars(t *testing.T) {
+	content := "VERSION: 1.10\nMODE: 0755\nENABLED: yes\nLARGE: 12345678901234567890\nQUOTED: '007'\n"
+	variables, err := parseVariablesFromReader(strings.NewReader(content), "-", FormatYAML, false)
+	if err != nil {
+		t.Fatalf("error parsing yaml: %s", err)
+	}
+	expected := []Variable{
+		{Name: "VERSION", Value: "1.10"},
+		{Name: "MODE", Value: "0755"},
+		{Name: "ENABLED", Value: "yes"},
+		{Name: "LARGE", Value: "12345678901234567890"},
+		{Name: "QUOTED", Value: "007"},
+	}
+	if !variablesEqual(variables, expected) {
+		t.Fatalf("variables don't match expected\n%v", variables)
+	}
+	if _, err := parseVariablesFromReader(strings.NewReader("one: [two]\n"), "-", FormatYAML, false); err == nil {
+		t.Fatalf("expected error for a value that isn't a scalar")
+	}
+}
+
+func TestParseDockerEnvFileFromEnvironment(t *testing.T) {
+	os.Setenv("ENVI_TEST_FIVE", "six")
+	defer os.Unsetenv("ENVI_TEST_FIVE")
+	variables, err := parseDockerEnvFile([]byte("one= two # verbatim\nENVI_TEST_FIVE\n"), false)
+	if err != nil {
+		t.Fatalf("error parsing docker env file: %s", err)
+	}
+	expected := []Variable{
+		{Name: "one", Value: " two # verbatim"},
+		{Name: "ENVI_TEST_FIVE", Value: "six"},
+	}
+	if !variablesEqual(variables, expected) {
+		t.Fatalf("variables don't match expected\n%v", variables)
+	}
+}
+
+func TestParseFormatsNameOnly(t *testing.T) {
+	variables, err := parseVariablesFromReader(strings.NewReader(`{"one": "two"}`), "-", "", true)
+	if err != nil {
+		t.Fatalf("error parsing json: %s", err)
+	}
+	if len(variables) != 1 || variables[0].Name != "one" || variables[0].Value != "" {
+		t.Fatalf("expected only names\n%v", variables)
+	}
+}
@@ -13,6 +13,9 @@ import (
 type Variable struct {
 	Name  string `dynamodbav:"name" json:"name"`
 	Value string `dynamodbav:"value" json:"value"`
+	// ContentType is set on variables holding the contents of a file.
+	// See FileVariable.
+	ContentType string `dynamodbav:"content_type,omitempty" json:"content_type,omitempty"`
 }
 
 // Item is the format of the configuratoin stored in dynamodb
@@ -58,69 +61,33 @@ func (item *Item) printShell() {
 	}
 }
 
-// TODO this is pretty darn primitive so make it more robust
-// support other formats and what not
-func parseVariables(variablesRaw string, nameOnly bool) []Variable {
-	keyValuePairs := strings.Split(variablesRaw, ",")
-	variables := make([]Variable, len(keyValuePairs))
-	for i, keyValuePair := range keyValuePairs {
-		if nameOnly {
-			variables[i] = Variable{
-				Name:  string(keyValuePair),
-				Value: "",
-			}
-		} else {
-			indexOfSeparator := strings.Index(keyValuePair, "=")
-			variables[i] = Variable{
-				Name:  string(keyValuePair[:indexOfSeparator]),
-				Value: string(keyValuePair[indexOfSeparator+1:]),
-			}
-		}
-	}
-	return variables
-}
-
-func parseVariablesFromFile(fileName string, nameOnly bool) ([]Variable, error) {
-	file, err := os.Open(fileName)
-	if err != nil {
-		return nil, err
-	}
-	defer file.Close()
-
-	fileScanner := bufio.NewScanner(file)
-	return parseVariablesFromScanner(fileScanner, nameOnly)
+// parseVariables parses variables given on the command line in the
+// form of key=value,key2=value2. Values containing commas can be quoted.
+func parseVariables(variablesRaw string, nameOnly bool) ([]Variable, error) {
+	parser := newDotenvParser(variablesRaw, ',', nameOnly)
+	parser.rules = parseRules(nameOnly)
+	variables, err := parser.parse()
+	return variables, validationError(err)
 }
 
+// parseVariablesFromScanner parses the lines of scanner as a dotenv file
 func parseVariablesFromScanner(scanner *bufio.Scanner, nameOnly bool) ([]Variable, error) {
-	variables := make([]Variable, 0)
+	return parseLines(scanner, nameOnly, parseRules(nameOnly))
+}
 
+// parseLines parses the lines of scanner as a dotenv file checking the
+// variables against rules if they aren't nil
+func parseLines(scanner *bufio.Scanner, nameOnly bool, rules *LintRules) ([]Variable, error) {
+	var lines []string
 	for scanner.Scan() {
-		// Parse line
-		line := scanner.Text()
-		line = strings.TrimPrefix(line, "export") // remove export if exists
-		line = strings.TrimLeft(line, " \t")      // remove all spaces on left
-		line = strings.TrimRight(line, " \t\n")   // trim right
-		if line == "" || line[0] == byte('#') {   // try to skip comments and empty lines
-			continue
-		}
-		var name, value string
-		if nameOnly {
-			name = line
-		} else {
-			indexOfSeparator := strings.Index(line, "=") // split into no more than 2 strings
-			if indexOfSeparator < 0 {
-				return variables, fmt.Errorf("error parsing file")
-			}
-			name = line[:indexOfSeparator]
-			value = line[indexOfSeparator+1:]
-		}
-		variables = append(variables, Variable{Name: name, Value: value})
+		lines = append(lines, scanner.Text())
 	}
-
 	if err := scanner.Err(); err != nil {
-		return variables, err
+		return nil, err
 	}
-	return variables, nil
+	parser := newDotenvParser(strings.Join(lines, "\n"), '\n', nameOnly)
+	parser.rules = rules
+	return parser.parse()
 }
 
 // CreateItem creates an item
@@ -0,0 +1,108 @@
+package store
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// posixName matches the names of environment variables that can be
+// exported by a posix shell
+var posixName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// LintRules are checked on variables when they are parsed and saved.
+// Variables that are only being deleted aren't checked so that invalid
+// variables can still be removed.
+type LintRules struct {
+	// PosixNames requires names to be made of letters, digits and _ and
+	// not start with a digit
+	PosixNames bool
+	// Uppercase requires names to be uppercase
+	Uppercase bool
+	// ForbiddenPrefixes can't start names, e.g. AWS_ or LD_
+	ForbiddenPrefixes []string
+	// MaxValueLength is the maximum length of a value in bytes. There is
+	// no maximum if it is zero.
+	MaxValueLength int
+	// NoDuplicates rejects names given more than once in the same input
+	NoDuplicates bool
+	// NoSurroundingWhitespace rejects values with leading or trailing
+	// whitespace, which is almost always a copy and paste mistake
+	NoSurroundingWhitespace bool
+}
+
+// DefaultLintRules are the rules used unless others are set
+var DefaultLintRules = LintRules{
+	PosixNames:              true,
+	NoDuplicates:            true,
+	NoSurroundingWhitespace: true,
+}
+
+// Check returns the problems of the variables. Values are never part of
+// the problems.
+func (rules *LintRules) Check(vars []Variable) []string {
+	problems := make([]string, 0)
+	seen := make(map[string]bool, len(vars))
+	for _, variable := range vars {
+		problems = append(problems, rules.problems(variable, seen)...)
+		seen[variable.Name] = true
+	}
+	return problems
+}
+
+// problems returns the problems of a variable. seen holds the names of
+// the variables before it in the same input.
+func (rules *LintRules) problems(variable Variable, seen map[string]bool) []string {
+	var problems []string
+	name := variable.Name
+	if rules.PosixNames && !posixName.MatchString(name) {
+		problems = append(problems, fmt.Sprintf("%q isn't a valid variable name", name))
+	}
+	if rules.Uppercase && name != strings.ToUpper(name) {
+		problems = append(problems, fmt.Sprintf("%s must be uppercase", name))
+	}
+	for _, prefix := range rules.ForbiddenPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			problems = append(problems, fmt.Sprintf("%s starts with the forbidden prefix %s", name, prefix))
+		}
+	}
+	if rules.MaxValueLength > 0 && len(variable.Value) > rules.MaxValueLength {
+		problems = append(problems, fmt.Sprintf("the value of %s is longer than %d bytes", name, rules.MaxValueLength))
+	}
+	if rules.NoDuplicates && seen[name] {
+		problems = append(problems, fmt.Sprintf("%s is set more than once", name))
+	}
+	if rules.NoSurroundingWhitespace && strings.TrimSpace(variable.Value) != variable.Value {
+		problems = append(problems, fmt.Sprintf("the value of %s has leading or trailing whitespace", name))
+	}
+	return problems
+}
+
+// lint returns a validation error if the variables break the rules of
+// the client
+func (c *Client) lint(vars []Variable) error {
+	return lintError(c.rules.Check(vars))
+}
+
+func lintError(problems []string) error {
+	if len(problems) == 0 {
+		return nil
+	}
+	return validationErrorf("%s", strings.Join(problems, "; "))
+}
+
+// parseRules returns the rules checked while parsing variables, none if
+// only names are parsed to delete variables
+func parseRules(nameOnly bool) *LintRules {
+	if nameOnly {
+		return nil
+	}
+	return &defaultClient.rules
+}
+
+// SetLintRules sets the rules checked by the package level functions
+func SetLintRules(rules LintRules) {
+	replaceDefaultClient(func(client *Client) {
+		client.rules = rules
+	})
+}
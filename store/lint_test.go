@@ -0,0 +1,94 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func TestLintRules(t *testing.T) {
+	rules := LintRules{
+		PosixNames:              true,
+		Uppercase:               true,
+		ForbiddenPrefixes:       []string{"AWS_"},
+		MaxValueLength:          8,
+		NoDuplicates:            true,
+		NoSurroundingWhitespace: true,
+	}
+	vars := []Variable{
+		{Name: "GOOD", Value: "value"},
+		{Name: "BAD NAME", Value: "value"},
+		{Name: "1ST", Value: "value"},
+		{Name: "lower", Value: "value"},
+		{Name: "AWS_REGION", Value: "us-east-1"},
+		{Name: "LONG", Value: "123456789"},
+		{Name: "GOOD", Value: "again"},
+		{Name: "PADDED", Value: " value"},
+	}
+	expected := []string{
+		`"BAD NAME" isn't a valid variable name`,
+		`"1ST" isn't a valid variable name`,
+		"lower must be uppercase",
+		"AWS_REGION starts with the forbidden prefix AWS_",
+		"the value of AWS_REGION is longer than 8 bytes",
+		"the value of LONG is longer than 8 bytes",
+		"GOOD is set more than once",
+		"the value of PADDED has leading or trailing whitespace",
+	}
+	problems := rules.Check(vars)
+	if strings.Join(problems, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("expected problems\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(problems, "\n"))
+	}
+	if problems := DefaultLintRules.Check(vars[:1]); len(problems) != 0 {
+		t.Fatalf("expected no problems, got %v", problems)
+	}
+}
+
+func TestLintParse(t *testing.T) {
+	content := "ONE=1\n\nTWO=\"multi\nline\"\nONE=2\n"
+	_, err := parseVariablesFromReader(strings.NewReader(content), "-", FormatEnv, false)
+	if !errors.Is(err, ErrValidation) || err.Error() != "line 5: ONE is set more than once" {
+		t.Fatalf("expected duplicate on line 5, got %v", err)
+	}
+	_, err = parseVariables(`ONE=1,TWO=" padded "`, false)
+	if err == nil || err.Error() != "variable 2: the value of TWO has leading or trailing whitespace" {
+		t.Fatalf("expected whitespace error, got %v", err)
+	}
+	_, err = parseVariablesFromReader(strings.NewReader(`{"BAD NAME": "x"}`), "-", FormatJSON, false)
+	if !errors.Is(err, ErrValidation) {
+		t.Fatalf("expected invalid name in json, got %v", err)
+	}
+	// names of variables to delete aren't checked
+	if _, err := parseVariables("BAD-NAME", true); err != nil {
+		t.Fatalf("error %s", err)
+	}
+}
+
+func TestLintSave(t *testing.T) {
+	mock := mockDynamoDBClient{items: map[string]map[string]*dynamodb.AttributeValue{}}
+	client, err := NewClient(WithTable("envi"), WithDB(mock), WithLintRules(LintRules{Uppercase: true}))
+	if err != nil {
+		t.Fatalf("error %s", err)
+	}
+	ctx := context.Background()
+	if err := client.Save(ctx, CreateItem("app__prod", []Variable{{Name: "lower", Value: "x"}})); !errors.Is(err, ErrValidation) {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+
+	// variables stored before the rules can still be renamed and deleted
+	if err := client.put(ctx, CreateItem("app__prod", []Variable{{Name: "lower", Value: "x"}, {Name: "other", Value: "y"}})); err != nil {
+		t.Fatalf("error %s", err)
+	}
+	if err := client.RenameVar(ctx, "app__prod", "lower", "still_lower"); !errors.Is(err, ErrValidation) {
+		t.Fatalf("expected validation error renaming, got %v", err)
+	}
+	if err := client.RenameVar(ctx, "app__prod", "lower", "UPPER"); err != nil {
+		t.Fatalf("error renaming %s", err)
+	}
+	if err := client.DeleteVars(ctx, "app__prod", []string{"other"}); err != nil {
+		t.Fatalf("error deleting %s", err)
+	}
+}
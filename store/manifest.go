@@ -0,0 +1,63 @@
+package store
+
+import (
+	"io"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// Manifest declares the desired variables of many items
+//
+//	prune: true     # remove variables that aren't declared
+//	configs:
+//	  billing__prod:
+//	    DB_HOST: db.internal
+//	  shipping__prod:
+//	    DB_HOST: db.internal
+type Manifest struct {
+	Prune bool
+	Items []Item
+}
+
+type manifestFile struct {
+	Prune   bool      `yaml:"prune"`
+	Configs yaml.Node `yaml:"configs"`
+}
+
+// ParseManifest reads a yaml, or json, manifest from r
+func ParseManifest(r io.Reader) (Manifest, error) {
+	var manifest Manifest
+	decoder := yaml.NewDecoder(r)
+	decoder.KnownFields(true)
+	var file manifestFile
+	if err := decoder.Decode(&file); err != nil && err != io.EOF {
+		return manifest, validationError(err)
+	}
+	manifest.Prune = file.Prune
+	switch file.Configs.Kind {
+	case 0: // no configs
+		return manifest, nil
+	case yaml.MappingNode:
+	default:
+		return manifest, validationErrorf("configs must be a map of ids to variables")
+	}
+	configs := file.Configs.Content
+	for i := 0; i+1 < len(configs); i += 2 {
+		id := configs[i].Value
+		var vars []Variable
+		value := configs[i+1]
+		switch {
+		case value.Kind == yaml.ScalarNode && value.Tag == "!!null": // a config without variables
+		case value.Kind == yaml.MappingNode:
+			var err error
+			vars, err = variablesFromMapping(value)
+			if err != nil {
+				return manifest, validationErrorf("%s: %s", id, err)
+			}
+		default:
+			return manifest, validationErrorf("%s: variables must be a map of names to values", id)
+		}
+		manifest.Items = append(manifest.Items, CreateItem(id, vars))
+	}
+	return manifest, nil
+}
@@ -0,0 +1,268 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// maximum number of actions, and of bytes they write, in a single
+// TransactWriteItems call
+const (
+	transactWriteLimit = 100
+	transactWriteSize  = 4 * 1024 * 1024
+)
+
+// Change is the difference between the stored and the desired state of
+// an item
+type Change struct {
+	ID string
+	// Create is true if the item doesn't exist yet
+	Create bool
+	// Delete is true if the whole item is deleted
+	Delete bool
+	// Added are variables that don't exist yet
+	Added []Variable
+	// Updated are variables whose values change, with their new values
+	Updated []Variable
+	// Removed are variables that are deleted, with their old values
+	Removed []Variable
+
+	// current and desired are the variables as they are stored before
+	// and will be stored after the change
+	current []Variable
+	desired Item
+}
+
+// Empty reports whether the change doesn't change anything
+func (change *Change) Empty() bool {
+	return !change.Create && !change.Delete &&
+		len(change.Added) == 0 && len(change.Updated) == 0 && len(change.Removed) == 0
+}
+
+// String describes the change without revealing any values
+func (change *Change) String() string {
+	var buffer bytes.Buffer
+	switch {
+	case change.Delete:
+		fmt.Fprintf(&buffer, "- %s (delete)\n", change.ID)
+		return buffer.String()
+	case change.Create:
+		fmt.Fprintf(&buffer, "+ %s (create)\n", change.ID)
+	default:
+		fmt.Fprintf(&buffer, "~ %s\n", change.ID)
+	}
+	for _, variable := range change.Added {
+		fmt.Fprintf(&buffer, "    + %s\n", variable.Name)
+	}
+	for _, variable := range change.Updated {
+		fmt.Fprintf(&buffer, "    ~ %s\n", variable.Name)
+	}
+	for _, variable := range change.Removed {
+		fmt.Fprintf(&buffer, "    - %s\n", variable.Name)
+	}
+	return buffer.String()
+}
+
+// diffVariables compares the current variables with the desired ones.
+// Variables that aren't desired are only removed if prune is true and
+// variables whose values or content types differ are updated. The
+// returned variables keep the order of current with new variables
+// appended.
+func diffVariables(current, desired []Variable, prune bool) (added, updated, removed, result []Variable) {
+	result = make([]Variable, 0, len(current)+len(desired))
+	for _, variable := range current {
+		wanted, found := findVariable(desired, variable.Name)
+		// a masked secret, e.g. from a dump, keeps the value it masks
+		if found && isMasked(wanted.Value) {
+			wanted.Value = variable.Value
+		}
+		switch {
+		case found && (wanted.Value != variable.Value || wanted.ContentType != variable.ContentType):
+			updated = append(updated, wanted)
+			result = append(result, wanted)
+		case found || !prune:
+			result = append(result, variable)
+		default:
+			removed = append(removed, variable)
+		}
+	}
+	for _, variable := range desired {
+		if !variableNamed(current, variable.Name) {
+			added = append(added, variable)
+			result = append(result, variable)
+		}
+	}
+	return added, updated, removed, result
+}
+
+func findVariable(vars []Variable, name string) (Variable, bool) {
+	for _, variable := range vars {
+		if variable.Name == name {
+			return variable, true
+		}
+	}
+	return Variable{}, false
+}
+
+// lookup gets the item with id 'id' and reports whether it exists
+func (c *Client) lookup(ctx context.Context, id string) (Item, bool, error) {
+	item, err := c.Get(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		return item, false, nil
+	}
+	return item, err == nil, err
+}
+
+// Plan compares the desired items with the stored ones and returns the
+// changes needed to store them. Variables that aren't desired are only
+// removed if prune is true.
+func Plan(desired []Item, prune bool) ([]Change, error) {
+	return defaultClient.Plan(context.Background(), desired, prune)
+}
+
+// Plan compares the desired items with the stored ones and returns the
+// changes needed to store them. Variables that aren't desired are only
+// removed if prune is true.
+func (c *Client) Plan(ctx context.Context, desired []Item, prune bool) ([]Change, error) {
+	changes := make([]Change, 0)
+	seen := make(map[string]bool)
+	for _, item := range desired {
+		if seen[item.ID] {
+			return nil, validationErrorf("%s is declared more than once", item.ID)
+		}
+		seen[item.ID] = true
+		if err := checkID(item.ID); err != nil {
+			return nil, err
+		}
+
+		if err := c.lint(item.Variables); err != nil {
+			return nil, fmt.Errorf("%s: %w", item.ID, err)
+		}
+		current, exists, err := c.lookup(ctx, item.ID)
+		if err != nil {
+			return nil, err
+		}
+		change := Change{ID: item.ID, Create: !exists, current: current.Variables}
+		var vars []Variable
+		change.Added, change.Updated, change.Removed, vars = diffVariables(current.Variables, item.Variables, prune)
+		// the value a masked secret stands for is only known if the
+		// secret is stored already
+		for _, variable := range change.Added {
+			if isMasked(variable.Value) {
+				return nil, validationErrorf("%s: %s is masked and isn't stored yet, so there is no value to keep", item.ID, variable.Name)
+			}
+		}
+		change.desired = CreateItem(item.ID, vars)
+		// the schema applies to the config as it will be stored, which
+		// keeps variables the manifest doesn't declare unless pruned
+		if err := c.checkSchema(ctx, change.desired); err != nil {
+			return nil, err
+		}
+		if !change.Empty() {
+			changes = append(changes, change)
+		}
+	}
+	return changes, nil
+}
+
+// ApplyChanges stores the changes returned by Plan in a single
+// transaction. DynamoDB limits the number and size of the items in a
+// transaction so if the changes exceed that they are applied in
+// consecutive transactions. Each transaction either applies completely
+// or not at all, failing if one of its configs changed since it was
+// planned, and the error says how many changes were applied before one
+// failed.
+func ApplyChanges(changes []Change) error {
+	return defaultClient.ApplyChanges(context.Background(), changes)
+}
+
+// ApplyChanges stores the changes returned by Plan in as few
+// transactions as possible. See the package level ApplyChanges.
+func (c *Client) ApplyChanges(ctx context.Context, changes []Change) error {
+	// the actions of a change are never split across transactions
+	transactions := make([][]*dynamodb.TransactWriteItem, 0, 1)
+	counts := make([]int, 0, 1)
+	var actions []*dynamodb.TransactWriteItem
+	count, size := 0, 0
+	for _, change := range changes {
+		changeActions, err := c.transactActions(ctx, change)
+		if err != nil {
+			return err
+		}
+		changeSize := actionsSize(changeActions)
+		if len(changeActions) > transactWriteLimit || changeSize > transactWriteSize {
+			return validationErrorf("%s is too large to change in a transaction", change.ID)
+		}
+		if len(actions)+len(changeActions) > transactWriteLimit || size+changeSize > transactWriteSize {
+			transactions = append(transactions, actions)
+			counts = append(counts, count)
+			actions, count, size = nil, 0, 0
+		}
+		actions = append(actions, changeActions...)
+		count++
+		size += changeSize
+	}
+	if len(actions) > 0 {
+		transactions = append(transactions, actions)
+		counts = append(counts, count)
+	}
+
+	applied := 0
+	for i, transaction := range transactions {
+		_, err := c.db.TransactWriteItemsWithContext(ctx, &dynamodb.TransactWriteItemsInput{
+			TransactItems: transaction,
+		})
+		if err != nil {
+			if _, ok := err.(*dynamodb.TransactionCanceledException); ok {
+				err = conflictErrorf("configs changed while applying; plan again: %s", err)
+			}
+			if applied == 0 {
+				return err
+			}
+			return fmt.Errorf("applied %d of %d changes before failing: %w", applied, len(changes), err)
+		}
+		for _, change := range changes[applied : applied+counts[i]] {
+			if err := c.record(ctx, AuditApply, change.ID, change.current, change.desired.Variables); err != nil {
+				return err
+			}
+		}
+		applied += counts[i]
+	}
+	return nil
+}
+
+// transactActions converts a change into transaction actions. The
+// actions are conditional on the item still not existing, or existing
+// with the variables it had, when the change was planned.
+func (c *Client) transactActions(ctx context.Context, change Change) ([]*dynamodb.TransactWriteItem, error) {
+	cond := itemNotExists
+	if !change.Create {
+		var err error
+		if cond, err = unchanged(change.current); err != nil {
+			return nil, err
+		}
+	}
+	if change.Delete {
+		return c.deleteActions(ctx, change.ID, cond)
+	}
+	return c.writeActions(ctx, CreateItem(change.ID, change.desired.Variables), cond)
+}
+
+// actionsSize returns the number of bytes the actions write, which
+// DynamoDB limits per transaction
+func actionsSize(actions []*dynamodb.TransactWriteItem) int {
+	size := 0
+	for _, action := range actions {
+		switch {
+		case action.Put != nil:
+			size += itemSize(action.Put.Item)
+		case action.Delete != nil:
+			size += itemSize(action.Delete.Key)
+		}
+	}
+	return size
+}
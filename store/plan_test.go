@@ -0,0 +1,186 @@
+package store
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+var testManifest = `prune: true
+configs:
+  billing__prod:
+    one: two
+    three: 3
+  shipping__prod:
+    seven: eight
+`
+
+func TestParseManifest(t *testing.T) {
+	manifest, err := ParseManifest(strings.NewReader(testManifest))
+	if err != nil {
+		t.Fatalf("error parsing manifest %s", err)
+	}
+	if !manifest.Prune || len(manifest.Items) != 2 {
+		t.Fatalf("manifest doesn't match expected %v", manifest)
+	}
+	expected := []Variable{{Name: "one", Value: "two"}, {Name: "three", Value: "3"}}
+	if manifest.Items[0].ID != "billing__prod" || !variablesEqual(manifest.Items[0].Variables, expected) {
+		t.Fatalf("manifest items don't match expected %v", manifest.Items)
+	}
+	// values are kept as written
+	manifest, err = ParseManifest(strings.NewReader("configs:\n  app__prod:\n    VERSION: 1.10\n    MODE: 0755\n  app__dev:\n"))
+	if err != nil {
+		t.Fatalf("error parsing manifest %s", err)
+	}
+	expected = []Variable{{Name: "VERSION", Value: "1.10"}, {Name: "MODE", Value: "0755"}}
+	if len(manifest.Items) != 2 || !variablesEqual(manifest.Items[0].Variables, expected) || len(manifest.Items[1].Variables) != 0 {
+		t.Fatalf("manifest items don't match expected %v", manifest.Items)
+	}
+	_, err = ParseManifest(strings.NewReader("configs:\n  app__prod: [one]\n"))
+	if err == nil {
+		t.Fatalf("expected error when variables aren't a map")
+	}
+}
+
+func TestPlanAndApply(t *testing.T) {
+	mock := mockDynamoDBClient{items: map[string]map[string]*dynamodb.AttributeValue{}}
+	SetDB(mock)
+	if err := Save("billing__prod", "one=one,three=3,five=six"); err != nil {
+		t.Fatalf("error %s", err)
+	}
+	if err := Save("unchanged__prod", "one=two"); err != nil {
+		t.Fatalf("error %s", err)
+	}
+	manifest, err := ParseManifest(strings.NewReader(testManifest + "  unchanged__prod:\n    one: two\n"))
+	if err != nil {
+		t.Fatalf("error parsing manifest %s", err)
+	}
+	changes, err := Plan(manifest.Items, manifest.Prune)
+	if err != nil {
+		t.Fatalf("error planning %s", err)
+	}
+	if len(changes) != 2 {
+		t.Fatalf("expected two changes, got %v", changes)
+	}
+	billing := changes[0]
+	if billing.Create || len(billing.Added) != 0 || len(billing.Updated) != 1 || len(billing.Removed) != 1 {
+		t.Fatalf("billing change doesn't match expected %v", billing)
+	}
+	if !changes[1].Create || len(changes[1].Added) != 1 {
+		t.Fatalf("shipping change doesn't match expected %v", changes[1])
+	}
+	if strings.Contains(billing.String(), "two") {
+		t.Fatalf("expected plan not to reveal values\n%s", billing.String())
+	}
+
+	if err := ApplyChanges(changes); err != nil {
+		t.Fatalf("error applying %s", err)
+	}
+	item, err := Get("billing__prod")
+	if err != nil {
+		t.Fatalf("error getting item %s", err)
+	}
+	expected := []Variable{{Name: "one", Value: "two"}, {Name: "three", Value: "3"}}
+	if !variablesEqual(item.Variables, expected) {
+		t.Fatalf("variables don't match expected %v", item.Variables)
+	}
+	if _, exists := mock.items["shipping__prod"]; !exists {
+		t.Fatalf("expected shipping__prod to be created")
+	}
+}
+
+func TestApplyConflict(t *testing.T) {
+	mock := mockDynamoDBClient{items: map[string]map[string]*dynamodb.AttributeValue{}}
+	SetDB(mock)
+	changes, err := Plan([]Item{CreateItem("app__prod", testItemOne.Variables)}, false)
+	if err != nil {
+		t.Fatalf("error planning %s", err)
+	}
+	// someone else creates the item after planning
+	if err := Save("app__prod", "seven=eight"); err != nil {
+		t.Fatalf("error %s", err)
+	}
+	if err := ApplyChanges(changes); err == nil {
+		t.Fatalf("expected error applying a stale plan")
+	}
+}
+
+func TestApplyStalePlan(t *testing.T) {
+	mock := mockDynamoDBClient{items: map[string]map[string]*dynamodb.AttributeValue{}}
+	SetDB(mock)
+	if err := Save("app__prod", "one=two"); err != nil {
+		t.Fatalf("error %s", err)
+	}
+	changes, err := Plan([]Item{CreateItem("app__prod", []Variable{{Name: "three", Value: "four"}})}, false)
+	if err != nil {
+		t.Fatalf("error planning %s", err)
+	}
+	// someone else changes the item after planning
+	if err := UpdateWithOptions("app__prod", "one=ten", UpdateOptions{}); err != nil {
+		t.Fatalf("error %s", err)
+	}
+	if err := ApplyChanges(changes); !errors.Is(err, ErrConflict) {
+		t.Fatalf("expected a conflict applying a stale plan, got %v", err)
+	}
+	item, err := Get("app__prod")
+	if err != nil || !variablesEqual(item.Variables, []Variable{{Name: "one", Value: "ten"}}) {
+		t.Fatalf("expected the other change to be kept %v %v", item.Variables, err)
+	}
+
+	// a plan of the current variables applies
+	changes, err = Plan([]Item{CreateItem("app__prod", []Variable{{Name: "three", Value: "four"}})}, false)
+	if err != nil {
+		t.Fatalf("error planning %s", err)
+	}
+	if err := ApplyChanges(changes); err != nil {
+		t.Fatalf("error applying %s", err)
+	}
+}
+
+func TestApplyLargeChanges(t *testing.T) {
+	mock := mockDynamoDBClient{items: map[string]map[string]*dynamodb.AttributeValue{}}
+	SetDB(mock)
+	// three configs of about 1.4MB don't fit in a 4MB transaction
+	desired := []Item{
+		CreateItem("one__prod", largeVariables(7, 200*1024)),
+		CreateItem("two__prod", largeVariables(7, 200*1024)),
+		CreateItem("three__prod", largeVariables(7, 200*1024)),
+	}
+	changes, err := Plan(desired, false)
+	if err != nil {
+		t.Fatalf("error planning %s", err)
+	}
+	if err := ApplyChanges(changes); err != nil {
+		t.Fatalf("error applying %s", err)
+	}
+	for _, item := range desired {
+		stored, err := Get(item.ID)
+		if err != nil || !variablesEqual(stored.Variables, item.Variables) {
+			t.Fatalf("expected %s to be applied %v", item.ID, err)
+		}
+	}
+}
+
+func TestPlanMaskedSecret(t *testing.T) {
+	mock := mockDynamoDBClient{items: map[string]map[string]*dynamodb.AttributeValue{}}
+	SetDB(mock)
+	if err := Save("app__prod", "DB_PASSWORD=hunter2"); err != nil {
+		t.Fatalf("error saving %s", err)
+	}
+	// the stored secret is kept
+	changes, err := PlanSync([]Item{CreateItem("app__prod", []Variable{{Name: "DB_PASSWORD", Value: Mask}})}, false, "")
+	if err != nil || len(changes) != 0 {
+		t.Fatalf("expected no changes for a masked stored secret %v %v", changes, err)
+	}
+	// there is no value to keep for a secret that isn't stored
+	for _, item := range []Item{
+		CreateItem("app__prod", []Variable{{Name: "API_TOKEN", Value: Mask}}),
+		CreateItem("app__dev", []Variable{{Name: "DB_PASSWORD", Value: "hmac:5d0ab3b1a0f5c8e2"}}),
+	} {
+		if _, err := PlanSync([]Item{item}, false, ""); !errors.Is(err, ErrValidation) {
+			t.Fatalf("expected a validation error for a masked secret that isn't stored, got %v", err)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package store
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// code of a transaction cancellation reason caused by a condition
+const conditionalCheckFailed = "ConditionalCheckFailed"
+
+// RenameVar renames the variable oldName of the item with id 'id' to
+// newName. The item is written in a single put so there is no point in
+// time where both or neither variable exist.
+func RenameVar(id, oldName, newName string) error {
+	return defaultClient.RenameVar(context.Background(), id, oldName, newName)
+}
+
+// RenameVar renames the variable oldName of the item with id 'id' to
+// newName in a single put
+func (c *Client) RenameVar(ctx context.Context, id, oldName, newName string) error {
+	if oldName == newName {
+		return validationErrorf("old and new names are the same")
+	}
+	if err := checkID(id); err != nil {
+		return err
+	}
+	item, err := c.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if variableNamed(item.Variables, newName) {
+		return conflictErrorf("%s already has a variable named %s", id, newName)
+	}
+	before := append([]Variable(nil), item.Variables...)
+	for i := range item.Variables {
+		if item.Variables[i].Name == oldName {
+			item.Variables[i].Name = newName
+			// only the new name is checked so that an invalid name can
+			// be renamed to a valid one
+			if err := c.lint(item.Variables[i : i+1]); err != nil {
+				return err
+			}
+			if err := c.put(ctx, item); err != nil {
+				return err
+			}
+			return c.record(ctx, AuditRenameVar, id, before, item.Variables)
+		}
+	}
+	return notFoundErrorf("%s has no variable named %s", id, oldName)
+}
+
+// Rename moves the item with id 'from' to id 'to'. The new item is
+// created and the old one deleted in a single transaction which fails if
+// 'to' already exists or 'from' changed or was deleted since it was read.
+func Rename(from, to string) error {
+	return defaultClient.Rename(context.Background(), from, to)
+}
+
+// Rename moves the item with id 'from' to id 'to' in a single
+// transaction
+func (c *Client) Rename(ctx context.Context, from, to string) error {
+	if from == to {
+		return validationErrorf("old and new ids are the same")
+	}
+	for _, id := range []string{from, to} {
+		if err := checkID(id); err != nil {
+			return err
+		}
+	}
+	item, err := c.Get(ctx, from)
+	if err != nil {
+		return err
+	}
+	item.ID = to
+	actions, err := c.writeActions(ctx, item, itemNotExists)
+	if err != nil {
+		return err
+	}
+	// the delete of the old head row follows the rows of the new item
+	deleteAt := len(actions)
+	cond, err := unchanged(item.Variables)
+	if err != nil {
+		return err
+	}
+	deletes, err := c.deleteActions(ctx, from, cond)
+	if err != nil {
+		return err
+	}
+	_, err = c.db.TransactWriteItemsWithContext(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: append(actions, deletes...),
+	})
+	if canceled, ok := err.(*dynamodb.TransactionCanceledException); ok {
+		if conditionFailed(canceled, 0) {
+			return conflictErrorf("can't rename %s: %s already exists", from, to)
+		}
+		if conditionFailed(canceled, deleteAt) {
+			return conflictErrorf("can't rename %s: it changed or was deleted while renaming", from)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	// the variables leave one id and arrive at the other
+	if err := c.record(ctx, AuditRename, from, item.Variables, nil); err != nil {
+		return err
+	}
+	return c.record(ctx, AuditRename, to, nil, item.Variables)
+}
+
+// conditionFailed reports whether the transaction was canceled because
+// the condition of its ith action failed
+func conditionFailed(canceled *dynamodb.TransactionCanceledException, i int) bool {
+	reasons := canceled.CancellationReasons
+	return i < len(reasons) && aws.StringValue(reasons[i].Code) == conditionalCheckFailed
+}
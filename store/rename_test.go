@@ -0,0 +1,98 @@
+package store
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func TestRenameVar(t *testing.T) {
+	mock := mockDynamoDBClient{items: map[string]map[string]*dynamodb.AttributeValue{}}
+	SetDB(mock)
+	if err := Save("app__test", testRawVariables); err != nil {
+		t.Fatalf("error %s", err)
+	}
+	if err := RenameVar("app__test", "three", "four"); err != nil {
+		t.Fatalf("error renaming variable %s", err)
+	}
+	item, err := Get("app__test")
+	if err != nil {
+		t.Fatalf("error getting item %s", err)
+	}
+	if variableExists(item.Variables, "three") || !variableExists(item.Variables, "four") {
+		t.Fatalf("expected variable to be renamed %v", item.Variables)
+	}
+	if err := RenameVar("app__test", "one", "four"); err == nil {
+		t.Fatalf("expected error renaming to an existing variable")
+	}
+	if err := RenameVar("app__test", "missing", "seven"); err == nil {
+		t.Fatalf("expected error renaming a missing variable")
+	}
+}
+
+func TestRename(t *testing.T) {
+	mock := mockDynamoDBClient{items: map[string]map[string]*dynamodb.AttributeValue{}}
+	SetDB(mock)
+	if err := Save("app__old", testRawVariables); err != nil {
+		t.Fatalf("error %s", err)
+	}
+	if err := Rename("app__old", "app__new"); err != nil {
+		t.Fatalf("error renaming %s", err)
+	}
+	if _, exists := mock.items["app__old"]; exists {
+		t.Fatalf("expected old id to be deleted")
+	}
+	item, err := Get("app__new")
+	if err != nil {
+		t.Fatalf("error getting item %s", err)
+	}
+	if !variablesEqual(item.Variables, testItemOne.Variables) {
+		t.Fatalf("variables don't match expected %v", item.Variables)
+	}
+}
+
+func TestRenameToExisting(t *testing.T) {
+	mock := mockDynamoDBClient{items: map[string]map[string]*dynamodb.AttributeValue{}}
+	SetDB(mock)
+	if err := Save("app__old", testRawVariables); err != nil {
+		t.Fatalf("error %s", err)
+	}
+	if err := Save("app__new", "seven=eight"); err != nil {
+		t.Fatalf("error %s", err)
+	}
+	if err := Rename("app__old", "app__new"); err == nil {
+		t.Fatalf("expected error renaming to an existing id")
+	}
+	if _, exists := mock.items["app__old"]; !exists {
+		t.Fatalf("expected old id to still exist")
+	}
+}
+
+// changingDynamoDBClient changes the item with id 'id' before every
+// transaction as if someone else wrote it in the meantime
+type changingDynamoDBClient struct {
+	mockDynamoDBClient
+	id string
+}
+
+func (m changingDynamoDBClient) TransactWriteItemsWithContext(ctx aws.Context, input *dynamodb.TransactWriteItemsInput, opts ...request.Option) (*dynamodb.TransactWriteItemsOutput, error) {
+	m.items[m.id][revisionAttribute] = &dynamodb.AttributeValue{S: aws.String("changed")}
+	return m.mockDynamoDBClient.TransactWriteItemsWithContext(ctx, input, opts...)
+}
+
+func TestRenameChanged(t *testing.T) {
+	mock := mockDynamoDBClient{items: map[string]map[string]*dynamodb.AttributeValue{}}
+	SetDB(changingDynamoDBClient{mockDynamoDBClient: mock, id: "app__old"})
+	if err := Save("app__old", testRawVariables); err != nil {
+		t.Fatalf("error %s", err)
+	}
+	if err := Rename("app__old", "app__new"); !errors.Is(err, ErrConflict) {
+		t.Fatalf("expected a conflict renaming a changed config, got %v", err)
+	}
+	if _, exists := mock.items["app__new"]; exists {
+		t.Fatalf("expected new id to not be created")
+	}
+}
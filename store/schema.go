@@ -0,0 +1,300 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// schemaPrefix starts the ids of the items holding the schemas of
+// applications. The schema of app is stored in _schema__app.
+const schemaPrefix = "_schema__"
+
+// schemaVariable is the variable of a schema item holding the schema
+const schemaVariable = "schema"
+
+// Types of variables declared in a schema
+const (
+	TypeString   = "string"
+	TypeInt      = "int"
+	TypeBool     = "bool"
+	TypeURL      = "url"
+	TypeDuration = "duration"
+	TypeEnum     = "enum"
+)
+
+// Schema declares the variables the configs of an application must
+// have. It is written in yaml:
+//
+//	variables:
+//	  PORT:
+//	    required: true
+//	    type: int
+//	  LOG_LEVEL:
+//	    type: enum
+//	    values: [debug, info, warn]
+//	  DB_PASSWORD:
+//	    required: true
+//	    secret: true
+//	    pattern: '.{16,}'
+type Schema struct {
+	Variables map[string]VariableSchema `yaml:"variables"`
+}
+
+// VariableSchema declares a variable of a schema
+type VariableSchema struct {
+	// Required variables must exist
+	Required bool `yaml:"required"`
+	// Type is one of the Type constants. TypeString accepts any value.
+	Type string `yaml:"type"`
+	// Values are the values allowed by TypeEnum
+	Values []string `yaml:"values"`
+	// Pattern is a regular expression the whole value must match
+	Pattern string `yaml:"pattern"`
+	// Secret marks the variable as a secret, masked like variables
+	// matching the secret patterns
+	Secret bool `yaml:"secret"`
+
+	pattern *regexp.Regexp
+}
+
+// ParseSchema reads a schema from r and checks that it is valid
+func ParseSchema(r io.Reader) (Schema, error) {
+	var schema Schema
+	decoder := yaml.NewDecoder(r)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&schema); err != nil && err != io.EOF {
+		return schema, validationError(err)
+	}
+	var err error
+	for name, variable := range schema.Variables {
+		switch variable.Type {
+		case "":
+			variable.Type = TypeString
+		case TypeString, TypeInt, TypeBool, TypeURL, TypeDuration:
+		case TypeEnum:
+			if len(variable.Values) == 0 {
+				return schema, validationErrorf("%s: enum must have values", name)
+			}
+		default:
+			return schema, validationErrorf("%s: unknown type %s", name, variable.Type)
+		}
+		if variable.Pattern != "" {
+			variable.pattern, err = regexp.Compile("^(?:" + variable.Pattern + ")$")
+			if err != nil {
+				return schema, validationErrorf("%s: bad pattern: %s", name, err)
+			}
+		}
+		schema.Variables[name] = variable
+	}
+	return schema, nil
+}
+
+// Secrets returns the names of the variables declared secret
+func (schema *Schema) Secrets() []string {
+	secrets := make([]string, 0)
+	for name, variable := range schema.Variables {
+		if variable.Secret {
+			secrets = append(secrets, name)
+		}
+	}
+	sort.Strings(secrets)
+	return secrets
+}
+
+// Check returns the problems of the variables, sorted by variable name.
+// Values are never part of the problems.
+func (schema *Schema) Check(vars []Variable) []string {
+	names := make([]string, 0, len(schema.Variables))
+	for name := range schema.Variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	problems := make([]string, 0)
+	for _, name := range names {
+		declared := schema.Variables[name]
+		variable, found := findVariable(vars, name)
+		if !found {
+			if declared.Required {
+				problems = append(problems, fmt.Sprintf("%s is required", name))
+			}
+			continue
+		}
+		if err := declared.check(variable.Value); err != nil {
+			problems = append(problems, fmt.Sprintf("%s %s", name, err))
+		}
+	}
+	return problems
+}
+
+func (declared *VariableSchema) check(value string) error {
+	var err error
+	switch declared.Type {
+	case TypeInt:
+		_, err = strconv.ParseInt(value, 10, 64)
+	case TypeBool:
+		_, err = strconv.ParseBool(value)
+	case TypeURL:
+		var parsed *url.URL
+		parsed, err = url.Parse(value)
+		if err == nil && (parsed.Scheme == "" || parsed.Host == "") {
+			err = errors.New("missing scheme or host")
+		}
+	case TypeDuration:
+		_, err = time.ParseDuration(value)
+	case TypeEnum:
+		if !slices.Contains(declared.Values, value) {
+			return fmt.Errorf("must be one of %s", strings.Join(declared.Values, ", "))
+		}
+	}
+	if err != nil {
+		// the errors of strconv and url quote the value which may be a
+		// secret
+		return fmt.Errorf("must be a %s", declared.Type)
+	}
+	if declared.pattern != nil && !declared.pattern.MatchString(value) {
+		return fmt.Errorf("must match %s", declared.Pattern)
+	}
+	return nil
+}
+
+// AppFromID returns the application of the config id, the part before
+// the first __
+func AppFromID(id string) string {
+	return strings.SplitN(id, "__", 2)[0]
+}
+
+// isSchemaID reports whether id is the id of an item holding a schema
+func isSchemaID(id string) bool {
+	return strings.HasPrefix(id, schemaPrefix)
+}
+
+// GetSchema returns the schema registered for the application app and
+// the yaml it was parsed from
+func GetSchema(app string) (Schema, string, error) {
+	return defaultClient.GetSchema(context.Background(), app)
+}
+
+// GetSchema returns the schema registered for the application app and
+// the yaml it was parsed from. The error is ErrNotFound if app has no
+// schema.
+func (c *Client) GetSchema(ctx context.Context, app string) (Schema, string, error) {
+	item, err := c.Get(ctx, schemaPrefix+app)
+	if errors.Is(err, ErrNotFound) {
+		return Schema{}, "", notFoundErrorf("%s has no schema", app)
+	}
+	if err != nil {
+		return Schema{}, "", err
+	}
+	raw, _ := findVariable(item.Variables, schemaVariable)
+	schema, err := ParseSchema(strings.NewReader(raw.Value))
+	if err != nil {
+		return schema, raw.Value, fmt.Errorf("schema of %s: %w", app, err)
+	}
+	return schema, raw.Value, nil
+}
+
+// SaveSchema registers the schema read from r for the application app
+func SaveSchema(app string, r io.Reader) error {
+	return defaultClient.SaveSchema(context.Background(), app, r)
+}
+
+// SaveSchema registers the schema read from r for the application app
+func (c *Client) SaveSchema(ctx context.Context, app string, r io.Reader) error {
+	if app == "" || strings.Contains(app, "__") {
+		return validationErrorf("bad application name %q", app)
+	}
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if _, err := ParseSchema(bytes.NewReader(content)); err != nil {
+		return err
+	}
+	item := CreateItem(schemaPrefix+app, []Variable{{Name: schemaVariable, Value: string(content)}})
+	before, err := c.auditing(ctx, item.ID)
+	if err != nil {
+		return err
+	}
+	if err := c.put(ctx, item); err != nil {
+		return err
+	}
+	return c.record(ctx, AuditSaveSchema, item.ID, before, item.Variables)
+}
+
+// SchemaSecrets returns the names of the variables declared secret by
+// the schema of the application app, none if it has no schema
+func (c *Client) SchemaSecrets(ctx context.Context, app string) ([]string, error) {
+	schema, _, err := c.GetSchema(ctx, app)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return schema.Secrets(), nil
+}
+
+// schemaFor returns the schema of the application of the config id and
+// whether there is one
+func (c *Client) schemaFor(ctx context.Context, id string) (Schema, bool, error) {
+	schema, _, err := c.GetSchema(ctx, AppFromID(id))
+	if errors.Is(err, ErrNotFound) {
+		return schema, false, nil
+	}
+	return schema, err == nil, err
+}
+
+// checkSchema checks the item against the schema of its application, if
+// there is one
+func (c *Client) checkSchema(ctx context.Context, item Item) error {
+	if isSchemaID(item.ID) {
+		return nil
+	}
+	schema, found, err := c.schemaFor(ctx, item.ID)
+	if err != nil || !found {
+		return err
+	}
+	return schemaError(item.ID, schema.Check(item.Variables))
+}
+
+func schemaError(id string, problems []string) error {
+	if len(problems) == 0 {
+		return nil
+	}
+	return validationErrorf("%s doesn't match the schema of %s: %s", id, AppFromID(id), strings.Join(problems, "; "))
+}
+
+// Validate checks the stored config with id 'id' against the schema of
+// its application. The error is ErrValidation if it doesn't match and
+// ErrNotFound if the config or the schema doesn't exist.
+func Validate(id string) error {
+	return defaultClient.Validate(context.Background(), id)
+}
+
+// Validate checks the stored config with id 'id' against the schema of
+// its application
+func (c *Client) Validate(ctx context.Context, id string) error {
+	item, err := c.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	schema, _, err := c.GetSchema(ctx, AppFromID(id))
+	if err != nil {
+		return err
+	}
+	return schemaError(id, schema.Check(item.Variables))
+}
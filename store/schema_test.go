@@ -0,0 +1,170 @@
+package store
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+const testSchema = `variables:
+  PORT:
+    required: true
+    type: int
+  DEBUG:
+    type: bool
+  API_URL:
+    type: url
+  TIMEOUT:
+    type: duration
+  LOG_LEVEL:
+    type: enum
+    values: [debug, info]
+  DB_PASSWORD:
+    secret: true
+    pattern: '.{8,}'
+`
+
+func TestParseSchema(t *testing.T) {
+	schema, err := ParseSchema(strings.NewReader(testSchema))
+	if err != nil {
+		t.Fatalf("error %s", err)
+	}
+	if secrets := schema.Secrets(); len(secrets) != 1 || secrets[0] != "DB_PASSWORD" {
+		t.Fatalf("unexpected secrets %v", secrets)
+	}
+
+	bad := []string{
+		"variables:\n  PORT:\n    type: float\n",
+		"variables:\n  LEVEL:\n    type: enum\n",
+		"variables:\n  NAME:\n    pattern: '('\n",
+		"variables:\n  NAME:\n    requried: true\n",
+	}
+	for _, content := range bad {
+		if _, err := ParseSchema(strings.NewReader(content)); !errors.Is(err, ErrValidation) {
+			t.Errorf("expected validation error for %q, got %v", content, err)
+		}
+	}
+}
+
+func TestParseSchemaKeepsScalars(t *testing.T) {
+	schema, err := ParseSchema(strings.NewReader("variables:\n  VERSION:\n    type: enum\n    values: [1.10, 1.9, 0755]\n"))
+	if err != nil {
+		t.Fatalf("error %s", err)
+	}
+	for _, value := range []string{"1.10", "1.9", "0755"} {
+		if problems := schema.Check([]Variable{{Name: "VERSION", Value: value}}); len(problems) > 0 {
+			t.Fatalf("expected %s to be allowed, got %v", value, problems)
+		}
+	}
+}
+
+func TestSchemaCheck(t *testing.T) {
+	schema, err := ParseSchema(strings.NewReader(testSchema))
+	if err != nil {
+		t.Fatalf("error %s", err)
+	}
+	valid := []Variable{
+		{Name: "PORT", Value: "8080"},
+		{Name: "DEBUG", Value: "true"},
+		{Name: "API_URL", Value: "https://api.internal/v1"},
+		{Name: "TIMEOUT", Value: "1m30s"},
+		{Name: "LOG_LEVEL", Value: "info"},
+		{Name: "DB_PASSWORD", Value: "correct horse"},
+		{Name: "UNDECLARED", Value: "anything"},
+	}
+	if problems := schema.Check(valid); len(problems) != 0 {
+		t.Fatalf("expected no problems, got %v", problems)
+	}
+
+	invalid := []Variable{
+		{Name: "DEBUG", Value: "maybe"},
+		{Name: "API_URL", Value: "api.internal"},
+		{Name: "TIMEOUT", Value: "90"},
+		{Name: "LOG_LEVEL", Value: "trace"},
+		{Name: "DB_PASSWORD", Value: "hunter2"},
+	}
+	expected := []string{
+		"API_URL must be a url",
+		"DB_PASSWORD must match .{8,}",
+		"DEBUG must be a bool",
+		"LOG_LEVEL must be one of debug, info",
+		"PORT is required",
+		"TIMEOUT must be a duration",
+	}
+	problems := schema.Check(invalid)
+	if strings.Join(problems, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("expected problems\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(problems, "\n"))
+	}
+}
+
+func TestSaveWithSchema(t *testing.T) {
+	mock := mockDynamoDBClient{items: map[string]map[string]*dynamodb.AttributeValue{}}
+	SetDB(mock)
+	if err := SaveSchema("app", strings.NewReader(testSchema)); err != nil {
+		t.Fatalf("error saving schema %s", err)
+	}
+	if err := Save("app__prod", "PORT=http"); !errors.Is(err, ErrValidation) {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+	if err := Save("app__prod", "PORT=8080"); err != nil {
+		t.Fatalf("error %s", err)
+	}
+	if err := UpdateWithOptions("app__prod", "PORT=", UpdateOptions{}); !errors.Is(err, ErrValidation) {
+		t.Fatalf("expected validation error updating, got %v", err)
+	}
+	// schemas are only written by SaveSchema
+	if err := Save("_schema__app", "X=1"); !errors.Is(err, ErrValidation) {
+		t.Fatalf("expected validation error overwriting a schema, got %v", err)
+	}
+	if err := Update("_schema__app", "X=1"); !errors.Is(err, ErrValidation) {
+		t.Fatalf("expected validation error updating a schema, got %v", err)
+	}
+	if _, _, err := GetSchema("app"); err != nil {
+		t.Fatalf("expected the schema to be intact, got %v", err)
+	}
+	// other applications aren't affected
+	if err := Save("other__prod", "PORT=http"); err != nil {
+		t.Fatalf("error %s", err)
+	}
+	if err := Validate("app__prod"); err != nil {
+		t.Fatalf("expected app__prod to be valid, got %s", err)
+	}
+	if err := Validate("other__prod"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected not found validating without a schema, got %v", err)
+	}
+
+	items, err := List("")
+	if err != nil {
+		t.Fatalf("error %s", err)
+	}
+	for _, item := range items {
+		if isSchemaID(item.ID) {
+			t.Fatalf("expected schemas not to be listed")
+		}
+	}
+}
+
+func TestPlanWithSchema(t *testing.T) {
+	mock := mockDynamoDBClient{items: map[string]map[string]*dynamodb.AttributeValue{}}
+	SetDB(mock)
+	if err := SaveSchema("app", strings.NewReader(testSchema)); err != nil {
+		t.Fatalf("error saving schema %s", err)
+	}
+	if err := Save("app__prod", "PORT=8080"); err != nil {
+		t.Fatalf("error %s", err)
+	}
+	// a partial manifest keeps the required variables already stored
+	partial := []Item{CreateItem("app__prod", []Variable{{Name: "DEBUG", Value: "true"}})}
+	if _, err := Plan(partial, false); err != nil {
+		t.Fatalf("error planning a partial manifest %s", err)
+	}
+	if _, err := Plan(partial, true); !errors.Is(err, ErrValidation) {
+		t.Fatalf("expected validation error pruning a required variable, got %v", err)
+	}
+	invalid := []Item{CreateItem("app__prod", []Variable{{Name: "DEBUG", Value: "maybe"}})}
+	if _, err := Plan(invalid, false); !errors.Is(err, ErrValidation) {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+}
@@ -1,18 +1,18 @@
 package store
 
 import (
+	"context"
+	"errors"
+	"slices"
+
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
-	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
 )
 
-var (
-	region    string
-	tableName string
-	db        dynamodbiface.DynamoDBAPI
-)
+// defaultClient is used by the package level functions
+var defaultClient = &Client{rules: DefaultLintRules, identity: &callerIdentity{}}
 
 // DynamodbItem is not what we want?
 type DynamodbItem struct {
@@ -23,160 +23,348 @@ type DynamodbItem struct {
 // Init sets up connection to dynamodb. This must be
 // called before using any other functions in the store package.
 func Init(regionName, table string) {
-	region = regionName
-	tableName = table
-	sesh := session.Must(session.NewSession(&aws.Config{Region: aws.String(region)}))
-	db = dynamodb.New(sesh)
+	if err := InitWithOptions(regionName, table, AWSOptions{}); err != nil {
+		panic(err)
+	}
+}
+
+// InitWithOptions is Init for a table reached with the options
+func InitWithOptions(regionName, table string, options AWSOptions) error {
+	db, stsClient, err := connect(regionName, options)
+	if err != nil {
+		return err
+	}
+	replaceDefaultClient(func(client *Client) {
+		client.region = regionName
+		client.tableName = table
+		client.db, client.sts = db, stsClient
+		client.aws = options
+		client.identity = &callerIdentity{}
+	})
+	return nil
 }
 
 // SetDB allows user to set db. Created for testing mostly
 func SetDB(newDB dynamodbiface.DynamoDBAPI) {
-	db = newDB
+	replaceDefaultClient(func(client *Client) {
+		client.db = newDB
+		client.sts = nil
+	})
+}
+
+// replaceDefaultClient replaces the default client with a copy changed
+// by change. Clients aren't modified after they are created since they
+// may be in use.
+func replaceDefaultClient(change func(*Client)) {
+	client := *defaultClient
+	change(&client)
+	defaultClient = &client
 }
 
 // Get gets the item that has an id of 'id'
 func Get(id string) (Item, error) {
-	return get(id)
+	return defaultClient.Get(context.Background(), id)
 }
 
-func get(id string) (Item, error) {
+// Get gets the item that has an id of 'id'. The error is ErrNotFound if
+// there is no such item.
+func (c *Client) Get(ctx context.Context, id string) (Item, error) {
 	var item Item
 	var err error
+	// the head row of a chunked item has to be as current as its chunks
 	params := &dynamodb.GetItemInput{
-		TableName: aws.String(tableName),
-		Key: map[string]*dynamodb.AttributeValue{
-			"id": {
-				S: aws.String(id),
-			},
-		},
-	}
-	resp, err := db.GetItem(params)
-	if err != nil {
-		return item, err
+		TableName:      aws.String(c.tableName),
+		Key:            key(id),
+		ConsistentRead: aws.Bool(true),
 	}
-	err = dynamodbattribute.UnmarshalMap(resp.Item, &item)
+	resp, err := c.db.GetItemWithContext(ctx, params)
 	if err != nil {
 		return item, err
 	}
-	item.decode()
-	return item, err
+	// dynamodb responds without an item rather than with an error
+	if len(resp.Item) == 0 {
+		return item, notFoundErrorf("%s doesn't exist", id)
+	}
+	return c.unmarshalItem(ctx, resp.Item)
 }
 
 // Save saves env vars given a string of vars in form of this=that,this2=that2
 func Save(id, vars string) error {
-	variables := parseVariables(vars, false)
-	item := CreateItem(id, variables)
-	return save(item)
+	variables, err := parseVariables(vars, false)
+	if err != nil {
+		return err
+	}
+	return SaveItem(CreateItem(id, variables))
 }
 
-// SaveFromFile gets env vars from a env file and saves to dynamo
+// SaveFromFile gets env vars from a file and saves to dynamo. The
+// format of the file is detected.
 func SaveFromFile(id, fileName string) error {
-	variables, err := parseVariablesFromFile(fileName, false)
+	return SaveFromFileWithFormat(id, fileName, "")
+}
+
+// SaveFromFileWithFormat gets env vars from a file in format and saves
+// to dynamo. The format of the file is detected if format is empty.
+func SaveFromFileWithFormat(id, fileName, format string) error {
+	variables, err := parseVariablesFromFile(fileName, format, false)
 	if err != nil {
 		return err
 	}
-	item := CreateItem(id, variables)
-	return save(item)
+	return SaveItem(CreateItem(id, variables))
 }
 
-func save(item Item) error {
-	item.encode()
-	atr, err := dynamodbattribute.MarshalMap(item)
+// SaveItem saves the item replacing all of the variables of the item
+// with the same id
+func SaveItem(item Item) error {
+	return defaultClient.Save(context.Background(), item)
+}
+
+// Save saves the item replacing all of the variables of the item with
+// the same id. The error is ErrValidation if the variables break the
+// lint rules of the client or the item doesn't match the schema of its
+// application.
+func (c *Client) Save(ctx context.Context, item Item) error {
+	if err := checkID(item.ID); err != nil {
+		return err
+	}
+	if err := c.lint(item.Variables); err != nil {
+		return err
+	}
+	before, err := c.auditing(ctx, item.ID)
 	if err != nil {
 		return err
 	}
-	params := &dynamodb.PutItemInput{
-		TableName: aws.String(tableName),
-		Item:      atr,
+	if err := c.put(ctx, item); err != nil {
+		return err
 	}
-	_, err = db.PutItem(params)
-	return err
+	return c.record(ctx, AuditSave, item.ID, before, item.Variables)
 }
 
-// Update updates configurate of given application with id
+// checkID returns a validation error if id can't be written as a
+// config. Rows holding chunks are only written with their config and
+// schemas only by SaveSchema.
+func checkID(id string) error {
+	switch {
+	case id == "":
+		return validationErrorf("must provide an id")
+	case isChunkID(id):
+		return validationErrorf("ids can't start with %s", chunkPrefix)
+	case isSchemaID(id):
+		return validationErrorf("ids starting with %s hold schemas, which are saved with SaveSchema", schemaPrefix)
+	}
+	return nil
+}
+
+// put stores the item without checking the lint rules so that items
+// with variables stored before the rules can still be changed
+func (c *Client) put(ctx context.Context, item Item) error {
+	if err := c.checkSchema(ctx, item); err != nil {
+		return err
+	}
+	return c.write(ctx, item)
+}
+
+// UpdateOptions configure how UpdateWithOptions and
+// UpdateFromFileWithOptions change an item
+type UpdateOptions struct {
+	// Create creates the item if it doesn't exist. The error is
+	// ErrNotFound otherwise.
+	Create bool
+	// Format is the format of the file, detected if empty
+	Format string
+}
+
+// Update updates configurate of given application with id. The item is
+// created if it doesn't exist.
 func Update(id, vars string) error {
-	parsedVars := parseVariables(vars, false)
-	return update(id, parsedVars)
+	return UpdateWithOptions(id, vars, UpdateOptions{Create: true})
+}
+
+// UpdateWithOptions updates configurate of given application with id as
+// set by options
+func UpdateWithOptions(id, vars string, options UpdateOptions) error {
+	parsedVars, err := parseVariables(vars, false)
+	if err != nil {
+		return err
+	}
+	return UpdateItem(CreateItem(id, parsedVars), options.Create)
 }
 
-// UpdateFromFile updates stuff from a file
+// UpdateFromFile updates stuff from a file. The format of the file is
+// detected and the item is created if it doesn't exist.
 func UpdateFromFile(id, fileName string) error {
-	vars, err := parseVariablesFromFile(fileName, false)
+	return UpdateFromFileWithOptions(id, fileName, UpdateOptions{Create: true})
+}
+
+// UpdateFromFileWithOptions updates stuff from a file as set by options
+func UpdateFromFileWithOptions(id, fileName string, options UpdateOptions) error {
+	vars, err := parseVariablesFromFile(fileName, options.Format, false)
 	if err != nil {
 		return err
 	}
-	return update(id, vars)
+	return UpdateItem(CreateItem(id, vars), options.Create)
 }
 
-func update(id string, vars []Variable) error {
-	item, err := get(id)
+// UpdateItem inserts new variables and updates existing variables of the
+// item with the same id, leaving other variables untouched
+func UpdateItem(item Item, create bool) error {
+	return defaultClient.Update(context.Background(), item, create)
+}
+
+// Update inserts new variables and updates existing variables of the
+// item with the same id, leaving other variables untouched. The error is
+// ErrNotFound if the item doesn't exist, unless create is true in which
+// case the item is created.
+func (c *Client) Update(ctx context.Context, update Item, create bool) error {
+	if err := checkID(update.ID); err != nil {
+		return err
+	}
+	if err := c.lint(update.Variables); err != nil {
+		return err
+	}
+	item, err := c.Get(ctx, update.ID)
 	if err != nil {
-		// Save the item if it doesn't exist already
-		if err.Error() == dynamodb.ErrCodeResourceNotFoundException {
-			return save(Item{
-				ID:        id,
-				Variables: vars,
-			})
+		if create && errors.Is(err, ErrNotFound) {
+			return c.Save(ctx, update)
 		}
 		return err
 	}
 
-	for i := 0; i < len(vars); i++ {
+	before := item.Variables
+	item.Variables = mergeVariables(item.Variables, update.Variables)
+	item.ID = update.ID
+	if err := c.put(ctx, item); err != nil {
+		return err
+	}
+	return c.record(ctx, AuditUpdate, item.ID, before, item.Variables)
+}
+
+// mergeVariables returns vars with the variables of update replacing
+// those with the same name and the others added
+func mergeVariables(vars, update []Variable) []Variable {
+	merged := append([]Variable(nil), vars...)
+	for i := 0; i < len(update); i++ {
 		found := false
-		for j := 0; j < len(item.Variables); j++ {
-			if vars[i].Name == item.Variables[j].Name {
+		for j := 0; j < len(merged); j++ {
+			if update[i].Name == merged[j].Name {
 				found = true
-				item.Variables[j].Value = vars[i].Value
+				merged[j] = update[i]
 				break
 			}
 		}
 		if !found { // add variable if not found already
-			item.Variables = append(item.Variables, vars[i])
+			merged = append(merged, update[i])
 		}
 	}
-	return save(item)
+	return merged
 }
 
 // Delete deletes the entire item the an id of 'id'
 func Delete(id string) error {
+	return defaultClient.Delete(context.Background(), id)
+}
+
+// Delete deletes the entire item the an id of 'id'. The error is
+// ErrNotFound if there is no such item.
+func (c *Client) Delete(ctx context.Context, id string) error {
+	before, err := c.auditing(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := c.delete(ctx, id); err != nil {
+		return err
+	}
+	return c.record(ctx, AuditDelete, id, before, nil)
+}
+
+func (c *Client) delete(ctx context.Context, id string) error {
+	actions, err := c.deleteActions(ctx, id, itemExists)
+	if err != nil {
+		return err
+	}
+	if len(actions) > 1 {
+		_, err = c.db.TransactWriteItemsWithContext(ctx, &dynamodb.TransactWriteItemsInput{
+			TransactItems: actions,
+		})
+		if canceled, ok := err.(*dynamodb.TransactionCanceledException); ok && conditionFailed(canceled, 0) {
+			return notFoundErrorf("%s doesn't exist", id)
+		}
+		return err
+	}
 	params := &dynamodb.DeleteItemInput{
-		TableName: aws.String(tableName),
-		Key: map[string]*dynamodb.AttributeValue{
-			"id": {
-				S: aws.String(id),
-			},
-		},
-	}
-	_, err := db.DeleteItem(params)
+		TableName:           aws.String(c.tableName),
+		Key:                 key(id),
+		ConditionExpression: aws.String("attribute_exists(id)"),
+	}
+	_, err = c.db.DeleteItemWithContext(ctx, params)
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return notFoundErrorf("%s doesn't exist", id)
+	}
 	return err
 }
 
 // DeleteVars deletes the given variables from the item with id of id
 func DeleteVars(id, variables string) error {
-	vars := parseVariables(variables, true)
-	return deleteVars(id, vars)
+	vars, err := parseVariables(variables, true)
+	if err != nil {
+		return err
+	}
+	return DeleteVarNames(id, variableNames(vars))
 }
 
-// DeleteVarsFromFile deletes the variables found in the file filepath
+// DeleteVarsFromFile deletes the variables found in the file filepath.
+// The format of the file is detected.
 func DeleteVarsFromFile(id, filePath string) error {
-	vars, err := parseVariablesFromFile(filePath, true)
+	return DeleteVarsFromFileWithFormat(id, filePath, "")
+}
+
+// DeleteVarsFromFileWithFormat deletes the variables found in the file
+// filepath in format. The format of the file is detected if format is
+// empty.
+func DeleteVarsFromFileWithFormat(id, filePath, format string) error {
+	vars, err := parseVariablesFromFile(filePath, format, true)
 	if err != nil {
 		return err
 	}
-	return deleteVars(id, vars)
+	return DeleteVarNames(id, variableNames(vars))
 }
 
-func deleteVars(id string, vars []Variable) error {
-	item, err := get(id)
+// DeleteVarNames deletes the variables named names from the item with
+// id of id
+func DeleteVarNames(id string, names []string) error {
+	return defaultClient.DeleteVars(context.Background(), id, names)
+}
+
+// DeleteVars deletes the variables named names from the item with id of
+// id. The error is ErrNotFound if there is no such item.
+func (c *Client) DeleteVars(ctx context.Context, id string, names []string) error {
+	if err := checkID(id); err != nil {
+		return err
+	}
+	item, err := c.Get(ctx, id)
 	if err != nil {
 		return err
 	}
-	for i := 0; i < len(vars); i++ {
-		for j := 0; j < len(item.Variables); j++ {
-			if vars[i].Name == item.Variables[j].Name {
-				item.Variables = append(item.Variables[:j], item.Variables[j+1:]...)
-			}
+	// build a new slice rather than removing in place so that the
+	// variable after a removed one isn't skipped
+	kept := make([]Variable, 0, len(item.Variables))
+	for _, variable := range item.Variables {
+		if !slices.Contains(names, variable.Name) {
+			kept = append(kept, variable)
 		}
 	}
-	return save(item)
+	before := item.Variables
+	item.Variables = kept
+	if err := c.put(ctx, item); err != nil {
+		return err
+	}
+	return c.record(ctx, AuditDeleteVars, id, before, item.Variables)
+}
+
+func variableNames(vars []Variable) []string {
+	names := make([]string, len(vars))
+	for i, variable := range vars {
+		names[i] = variable.Name
+	}
+	return names
 }
@@ -3,10 +3,16 @@ package store
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
 	"testing"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
 )
@@ -47,7 +53,7 @@ var (
 		},
 	}
 
-	// doesn't handle comments in middle or end of lines
+	// whitespace around unquoted names and values is trimmed
 	testFileContent = `  one=two
 
 	three=four 
@@ -82,13 +88,8 @@ type mockDynamoDBClient struct {
 // TODO maybe use dynamodbattribute to make this easier to read at a
 // glance for those who don't speak dynamodb
 func (m mockDynamoDBClient) GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
-	output := &dynamodb.GetItemOutput{}
-	item, exists := m.items[*input.Key["id"].S]
-	if !exists {
-		return nil, fmt.Errorf(dynamodb.ErrCodeResourceNotFoundException)
-	}
-	output.Item = item
-	return output, nil
+	// like dynamodb a missing item is an empty output rather than an error
+	return &dynamodb.GetItemOutput{Item: m.items[*input.Key["id"].S]}, nil
 }
 
 func (m mockDynamoDBClient) PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
@@ -97,12 +98,111 @@ func (m mockDynamoDBClient) PutItem(input *dynamodb.PutItemInput) (*dynamodb.Put
 }
 
 func (m mockDynamoDBClient) DeleteItem(input *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error) {
+	if _, exists := m.items[*input.Key["id"].S]; !exists && aws.StringValue(input.ConditionExpression) == "attribute_exists(id)" {
+		return nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "The conditional request failed", nil)
+	}
 	delete(m.items, *input.Key["id"].S)
 	return &dynamodb.DeleteItemOutput{}, nil
 }
 
+// Scan returns pages of at most two items to exercise pagination
+func (m mockDynamoDBClient) Scan(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+	ids := make([]string, 0, len(m.items))
+	for id := range m.items {
+		if input.ExclusiveStartKey == nil || id > *input.ExclusiveStartKey["id"].S {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	output := &dynamodb.ScanOutput{}
+	for i, id := range ids {
+		if i == 2 {
+			output.LastEvaluatedKey = map[string]*dynamodb.AttributeValue{"id": {S: aws.String(ids[i-1])}}
+			break
+		}
+		if input.FilterExpression != nil && !strings.HasPrefix(id, *input.ExpressionAttributeValues[":prefix"].S) {
+			continue
+		}
+		output.Items = append(output.Items, m.items[id])
+	}
+	return output, nil
+}
+
+func (m mockDynamoDBClient) BatchWriteItem(input *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+	for _, requests := range input.RequestItems {
+		if len(requests) > batchWriteLimit {
+			return nil, fmt.Errorf("too many items in batch")
+		}
+		for _, request := range requests {
+			if request.PutRequest != nil {
+				m.items[*request.PutRequest.Item["id"].S] = request.PutRequest.Item
+			}
+			if request.DeleteRequest != nil {
+				delete(m.items, *request.DeleteRequest.Key["id"].S)
+			}
+		}
+	}
+	return &dynamodb.BatchWriteItemOutput{}, nil
+}
+
+// TransactWriteItems only understands the conditions of itemExists,
+// itemNotExists and unchanged. Like DynamoDB it rejects transactions
+// with too many actions or bytes.
+func (m mockDynamoDBClient) TransactWriteItems(input *dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, error) {
+	if len(input.TransactItems) > transactWriteLimit || actionsSize(input.TransactItems) > transactWriteSize {
+		return nil, fmt.Errorf("transaction is too large")
+	}
+	reasons := make([]*dynamodb.CancellationReason, len(input.TransactItems))
+	canceled := false
+	for i, transactItem := range input.TransactItems {
+		var id string
+		var condition *string
+		var values map[string]*dynamodb.AttributeValue
+		switch {
+		case transactItem.Put != nil:
+			id, condition, values = *transactItem.Put.Item["id"].S, transactItem.Put.ConditionExpression, transactItem.Put.ExpressionAttributeValues
+		case transactItem.Delete != nil:
+			id, condition, values = *transactItem.Delete.Key["id"].S, transactItem.Delete.ConditionExpression, transactItem.Delete.ExpressionAttributeValues
+		case transactItem.ConditionCheck != nil:
+			id, condition = *transactItem.ConditionCheck.Key["id"].S, transactItem.ConditionCheck.ConditionExpression
+		}
+		row, exists := m.items[id]
+		reasons[i] = &dynamodb.CancellationReason{Code: aws.String("None")}
+		failed := false
+		switch {
+		case condition == nil:
+		case *condition == itemExists.expression:
+			failed = !exists
+		case *condition == itemNotExists.expression:
+			failed = exists
+		case values[":revision"] != nil:
+			stored := row[revisionAttribute]
+			failed = !exists || (stored != nil && *stored.S != *values[":revision"].S)
+		}
+		if failed {
+			reasons[i].Code = aws.String(conditionalCheckFailed)
+			canceled = true
+		}
+	}
+	if canceled {
+		return nil, &dynamodb.TransactionCanceledException{CancellationReasons: reasons}
+	}
+	for _, transactItem := range input.TransactItems {
+		if transactItem.Put != nil {
+			m.items[*transactItem.Put.Item["id"].S] = transactItem.Put.Item
+		}
+		if transactItem.Delete != nil {
+			delete(m.items, *transactItem.Delete.Key["id"].S)
+		}
+	}
+	return &dynamodb.TransactWriteItemsOutput{}, nil
+}
+
 func TestParseVariables(t *testing.T) {
-	variables := parseVariables(testRawVariables, false)
+	variables, err := parseVariables(testRawVariables, false)
+	if err != nil {
+		t.Fatalf("error parsing variables: %s", err)
+	}
 	if len(variables) != len(testItemOne.Variables) {
 		t.Fatalf("length of variables not expected value")
 	}
@@ -165,7 +265,11 @@ func TestEncoding(t *testing.T) {
 }
 
 func TestCreateItem(t *testing.T) {
-	newItem := CreateItem("app_one", parseVariables(testRawVariables, false))
+	variables, err := parseVariables(testRawVariables, false)
+	if err != nil {
+		t.Fatalf("error parsing variables: %s", err)
+	}
+	newItem := CreateItem("app_one", variables)
 	if newItem.ID != "app_one" {
 		t.Fatalf("Wow thats embarrassing")
 	}
@@ -179,7 +283,7 @@ func variablesEqual(one, two []Variable) bool {
 		return false
 	}
 	for i := range one {
-		if one[i].Name != two[i].Name || one[i].Value != two[i].Value {
+		if one[i].Name != two[i].Name || one[i].Value != two[i].Value || one[i].ContentType != two[i].ContentType {
 			return false
 		}
 	}
@@ -263,6 +367,34 @@ func TestDeleteMultipleVars(t *testing.T) {
 	}
 }
 
+func TestDeleteAdjacentVars(t *testing.T) {
+	mock := mockDynamoDBClient{items: map[string]map[string]*dynamodb.AttributeValue{}}
+	SetDB(mock)
+	// removing a variable used to skip the one after it. The duplicates
+	// are put directly because the lint rules reject them.
+	err := defaultClient.put(context.Background(), CreateItem("app__test", []Variable{
+		{Name: "one", Value: "two"},
+		{Name: "three", Value: "four"},
+		{Name: "three", Value: "five"},
+		{Name: "seven", Value: "eight"},
+	}))
+	if err != nil {
+		t.Fatalf("error %s", err)
+	}
+	err = DeleteVars("app__test", "three")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	item, err := Get("app__test")
+	if err != nil {
+		t.Fatalf("error getting item %s", err)
+	}
+	expected := []Variable{{Name: "one", Value: "two"}, {Name: "seven", Value: "eight"}}
+	if !variablesEqual(item.Variables, expected) {
+		t.Fatalf("expected adjacent variables to be deleted %v", item.Variables)
+	}
+}
+
 func TestUpdateVariable(t *testing.T) {
 	mock := mockDynamoDBClient{items: map[string]map[string]*dynamodb.AttributeValue{}}
 	SetDB(mock)
@@ -270,7 +402,7 @@ func TestUpdateVariable(t *testing.T) {
 	if err != nil {
 		t.Fatalf("error %s", err)
 	}
-	err = Update("app__test", "one=ten")
+	err = UpdateWithOptions("app__test", "one=ten", UpdateOptions{})
 	if err != nil {
 		t.Fatalf("error %s", err)
 	}
@@ -292,7 +424,7 @@ func TestUpdateAddVariable(t *testing.T) {
 	if err != nil {
 		t.Fatalf("error %s", err)
 	}
-	err = Update("app__test", "seven=eight")
+	err = UpdateWithOptions("app__test", "seven=eight", UpdateOptions{})
 	if err != nil {
 		t.Fatalf("error %s", err)
 	}
@@ -317,7 +449,11 @@ func TestUpdateAddVariable(t *testing.T) {
 func TestUpdateAddItem(t *testing.T) {
 	mock := mockDynamoDBClient{items: map[string]map[string]*dynamodb.AttributeValue{}}
 	SetDB(mock)
-	err := Update("app__testNew", "seven=eight")
+	err := UpdateWithOptions("app__testNew", "seven=eight", UpdateOptions{})
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected not found updating without create, got %v", err)
+	}
+	err = Update("app__testNew", "seven=eight")
 	if err != nil {
 		t.Fatalf("error %s", err)
 	}
@@ -327,10 +463,62 @@ func TestUpdateAddItem(t *testing.T) {
 	}
 }
 
+func TestGetMissing(t *testing.T) {
+	mock := mockDynamoDBClient{items: map[string]map[string]*dynamodb.AttributeValue{}}
+	SetDB(mock)
+	if _, err := Get("app__missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
+
+func TestDeleteMissing(t *testing.T) {
+	mock := mockDynamoDBClient{items: map[string]map[string]*dynamodb.AttributeValue{}}
+	SetDB(mock)
+	if err := Delete("app__missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected not found deleting, got %v", err)
+	}
+	// deleting variables used to create an empty item
+	if err := DeleteVars("app__missing", "one"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected not found deleting variables, got %v", err)
+	}
+	if len(mock.items) != 0 {
+		t.Fatalf("expected no items to be created, got %d", len(mock.items))
+	}
+}
+
 func TestInit(t *testing.T) {
-	db = nil
+	defaultClient = &Client{}
 	Init("us-east-1", "envi")
-	if db == nil {
+	if defaultClient.db == nil {
 		t.Fatalf("expected db to initialized")
 	}
+	if defaultClient.Table() != "envi" {
+		t.Fatalf("expected table envi, got %s", defaultClient.Table())
+	}
+}
+
+// The store calls the WithContext variants of the dynamodb api
+
+func (m mockDynamoDBClient) GetItemWithContext(ctx aws.Context, input *dynamodb.GetItemInput, opts ...request.Option) (*dynamodb.GetItemOutput, error) {
+	return m.GetItem(input)
+}
+
+func (m mockDynamoDBClient) PutItemWithContext(ctx aws.Context, input *dynamodb.PutItemInput, opts ...request.Option) (*dynamodb.PutItemOutput, error) {
+	return m.PutItem(input)
+}
+
+func (m mockDynamoDBClient) DeleteItemWithContext(ctx aws.Context, input *dynamodb.DeleteItemInput, opts ...request.Option) (*dynamodb.DeleteItemOutput, error) {
+	return m.DeleteItem(input)
+}
+
+func (m mockDynamoDBClient) ScanWithContext(ctx aws.Context, input *dynamodb.ScanInput, opts ...request.Option) (*dynamodb.ScanOutput, error) {
+	return m.Scan(input)
+}
+
+func (m mockDynamoDBClient) BatchWriteItemWithContext(ctx aws.Context, input *dynamodb.BatchWriteItemInput, opts ...request.Option) (*dynamodb.BatchWriteItemOutput, error) {
+	return m.BatchWriteItem(input)
+}
+
+func (m mockDynamoDBClient) TransactWriteItemsWithContext(ctx aws.Context, input *dynamodb.TransactWriteItemsInput, opts ...request.Option) (*dynamodb.TransactWriteItemsOutput, error) {
+	return m.TransactWriteItems(input)
 }
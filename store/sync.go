@@ -0,0 +1,106 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// extensions of the files read by ReadConfigDir
+var configExtensions = map[string]bool{
+	".env":  true,
+	".sh":   true,
+	".json": true,
+	".yaml": true,
+	".yml":  true,
+}
+
+// idFromPath derives the id of the item stored in the file at path
+// relative to the config directory: app/env.ext is app__env and
+// name.ext is name
+func idFromPath(relativePath string) (string, error) {
+	name := strings.TrimSuffix(relativePath, filepath.Ext(relativePath))
+	parts := strings.Split(filepath.ToSlash(name), "/")
+	switch len(parts) {
+	case 1:
+		return parts[0], nil
+	case 2:
+		return parts[0] + "__" + parts[1], nil
+	}
+	return "", fmt.Errorf("%s is nested too deep; expected <app>/<environment>%s", relativePath, filepath.Ext(relativePath))
+}
+
+// ReadConfigDir reads the items stored in the directory dir where the
+// file dir/app/env.env, or .json, .yaml etc, holds the variables of the
+// item with id app__env
+func ReadConfigDir(dir string) ([]Item, error) {
+	items := make([]Item, 0)
+	paths := make(map[string]string)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(info.Name(), ".") && path != dir {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() || !configExtensions[strings.ToLower(filepath.Ext(path))] {
+			return nil
+		}
+
+		relativePath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		id, err := idFromPath(relativePath)
+		if err != nil {
+			return err
+		}
+		if other, exists := paths[id]; exists {
+			return fmt.Errorf("%s and %s both hold %s", other, path, id)
+		}
+		paths[id] = path
+
+		vars, err := parseVariablesFromFile(path, "", false)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		items = append(items, CreateItem(id, vars))
+		return nil
+	})
+	sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+	return items, err
+}
+
+// PlanSync returns the changes needed to make the stored items match the
+// desired ones exactly. If pruneIDs is true, stored items whose ids
+// start with prefix but aren't desired are deleted.
+func PlanSync(desired []Item, pruneIDs bool, prefix string) ([]Change, error) {
+	return defaultClient.PlanSync(context.Background(), desired, pruneIDs, prefix)
+}
+
+// PlanSync returns the changes needed to make the stored items match the
+// desired ones exactly. If pruneIDs is true, stored items whose ids
+// start with prefix but aren't desired are deleted.
+func (c *Client) PlanSync(ctx context.Context, desired []Item, pruneIDs bool, prefix string) ([]Change, error) {
+	changes, err := c.Plan(ctx, desired, true)
+	if err != nil || !pruneIDs {
+		return changes, err
+	}
+	wanted := make(map[string]bool, len(desired))
+	for _, item := range desired {
+		wanted[item.ID] = true
+	}
+	err = c.scan(ctx, prefix, func(item Item) error {
+		if !wanted[item.ID] && !isSchemaID(item.ID) {
+			changes = append(changes, Change{ID: item.ID, Delete: true, Removed: item.Variables, current: item.Variables})
+		}
+		return nil
+	})
+	return changes, err
+}
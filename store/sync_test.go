@@ -0,0 +1,101 @@
+package store
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func writeTestConfigDir(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "envi")
+	if err != nil {
+		t.Fatalf("error creating temp dir %s", err)
+	}
+	for path, content := range files {
+		path = filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("error creating dir %s", err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("error writing file %s", err)
+		}
+	}
+	return dir
+}
+
+func TestReadConfigDir(t *testing.T) {
+	dir := writeTestConfigDir(t, map[string]string{
+		"app/prod.env":       testFileContent,
+		"app/dev.yaml":       "one: two\n",
+		"other.json":         `{"seven": "eight"}`,
+		"app/README.md":      "not a config",
+		".git/config.env":    "hidden=true",
+		"app/.secret/x.env":  "hidden=true",
+		"app/.override.yaml": "hidden: true",
+	})
+	defer os.RemoveAll(dir)
+
+	items, err := ReadConfigDir(dir)
+	if err != nil {
+		t.Fatalf("error reading config dir %s", err)
+	}
+	if len(items) != 3 {
+		t.Fatalf("expected three items, got %v", items)
+	}
+	if items[0].ID != "app__dev" || items[1].ID != "app__prod" || items[2].ID != "other" {
+		t.Fatalf("ids don't match expected %v", items)
+	}
+	if !variablesEqual(items[1].Variables, testItemOne.Variables) {
+		t.Fatalf("variables don't match expected %v", items[1].Variables)
+	}
+}
+
+func TestReadConfigDirDuplicateID(t *testing.T) {
+	dir := writeTestConfigDir(t, map[string]string{
+		"app/prod.env":  "one=two",
+		"app/prod.yaml": "one: two",
+	})
+	defer os.RemoveAll(dir)
+	if _, err := ReadConfigDir(dir); err == nil {
+		t.Fatalf("expected error when two files hold the same id")
+	}
+}
+
+func TestPlanSync(t *testing.T) {
+	mock := mockDynamoDBClient{items: map[string]map[string]*dynamodb.AttributeValue{}}
+	SetDB(mock)
+	for _, id := range []string{"app__prod", "app__old", "other__prod"} {
+		if err := Save(id, "one=two,extra=gone"); err != nil {
+			t.Fatalf("error %s", err)
+		}
+	}
+	desired := []Item{CreateItem("app__prod", []Variable{{Name: "one", Value: "two"}})}
+
+	changes, err := PlanSync(desired, false, "")
+	if err != nil {
+		t.Fatalf("error planning %s", err)
+	}
+	if len(changes) != 1 || len(changes[0].Removed) != 1 {
+		t.Fatalf("expected undeclared variables to be removed %v", changes)
+	}
+
+	changes, err = PlanSync(desired, true, "app__")
+	if err != nil {
+		t.Fatalf("error planning %s", err)
+	}
+	if len(changes) != 2 || !changes[1].Delete || changes[1].ID != "app__old" {
+		t.Fatalf("expected app__old to be pruned %v", changes)
+	}
+	if err := ApplyChanges(changes); err != nil {
+		t.Fatalf("error applying %s", err)
+	}
+	if _, exists := mock.items["app__old"]; exists {
+		t.Fatalf("expected app__old to be deleted")
+	}
+	if _, exists := mock.items["other__prod"]; !exists {
+		t.Fatalf("expected ids outside of prefix to be kept")
+	}
+}
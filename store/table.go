@@ -0,0 +1,351 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// TableOptions control how CreateTable creates the table
+type TableOptions struct {
+	// BillingMode is dynamodb.BillingModePayPerRequest, the default, or
+	// dynamodb.BillingModeProvisioned
+	BillingMode string
+	// ReadCapacity and WriteCapacity are the capacity units of a table,
+	// and its indexes, in the provisioned billing mode
+	ReadCapacity  int64
+	WriteCapacity int64
+	// Indexes are the global secondary indexes of the table
+	Indexes []IndexOptions
+	// TTLAttribute is the attribute holding the time items expire. Time
+	// to live isn't enabled if it is empty.
+	TTLAttribute string
+	// PointInTimeRecovery enables continuous backups
+	PointInTimeRecovery bool
+	// StreamViewType enables a stream of changes to the table with
+	// records of the type, e.g. dynamodb.StreamViewTypeNewAndOldImages
+	StreamViewType string
+}
+
+// IndexOptions describe a global secondary index
+type IndexOptions struct {
+	Name string
+	// Attribute is the string attribute the index is keyed by
+	Attribute string
+}
+
+// CreateTable creates the table with the options. It is idempotent:
+// settings missing from an existing table are added and it is an error
+// if the table exists with a different key.
+func CreateTable(options TableOptions) error {
+	return defaultClient.CreateTable(context.Background(), options)
+}
+
+// CreateTable creates the table with the options. See the package level
+// CreateTable.
+func (c *Client) CreateTable(ctx context.Context, options TableOptions) error {
+	if options.BillingMode == "" {
+		options.BillingMode = dynamodb.BillingModePayPerRequest
+	}
+	var throughput *dynamodb.ProvisionedThroughput
+	switch options.BillingMode {
+	case dynamodb.BillingModePayPerRequest:
+	case dynamodb.BillingModeProvisioned:
+		if options.ReadCapacity <= 0 || options.WriteCapacity <= 0 {
+			return validationErrorf("read and write capacity must be provided in the provisioned billing mode")
+		}
+		throughput = &dynamodb.ProvisionedThroughput{
+			ReadCapacityUnits:  aws.Int64(options.ReadCapacity),
+			WriteCapacityUnits: aws.Int64(options.WriteCapacity),
+		}
+	default:
+		return validationErrorf("unknown billing mode %s", options.BillingMode)
+	}
+
+	table, err := c.describeTable(ctx)
+	if errors.Is(err, ErrNotFound) {
+		table, err = c.createTable(ctx, options, throughput)
+	}
+	if err != nil {
+		return err
+	}
+	if problem := keyProblem(table); problem != "" {
+		return conflictErrorf("%s already exists: %s", c.tableName, problem)
+	}
+
+	for _, index := range options.Indexes {
+		if hasIndex(table, index.Name) {
+			continue
+		}
+		_, err := c.db.UpdateTableWithContext(ctx, &dynamodb.UpdateTableInput{
+			TableName:            aws.String(c.tableName),
+			AttributeDefinitions: []*dynamodb.AttributeDefinition{stringAttribute(index.Attribute)},
+			GlobalSecondaryIndexUpdates: []*dynamodb.GlobalSecondaryIndexUpdate{
+				{Create: &dynamodb.CreateGlobalSecondaryIndexAction{
+					IndexName:             aws.String(index.Name),
+					KeySchema:             hashKey(index.Attribute),
+					Projection:            &dynamodb.Projection{ProjectionType: aws.String(dynamodb.ProjectionTypeAll)},
+					ProvisionedThroughput: throughput,
+				}},
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("adding index %s: %w", index.Name, err)
+		}
+		if err := c.waitForTable(ctx); err != nil {
+			return err
+		}
+	}
+	if options.StreamViewType != "" && (table.StreamSpecification == nil || !aws.BoolValue(table.StreamSpecification.StreamEnabled)) {
+		_, err := c.db.UpdateTableWithContext(ctx, &dynamodb.UpdateTableInput{
+			TableName:           aws.String(c.tableName),
+			StreamSpecification: streamSpecification(options.StreamViewType),
+		})
+		if err != nil {
+			return fmt.Errorf("enabling the stream: %w", err)
+		}
+		if err := c.waitForTable(ctx); err != nil {
+			return err
+		}
+	}
+	if options.TTLAttribute != "" {
+		if err := c.enableTTL(ctx, options.TTLAttribute); err != nil {
+			return fmt.Errorf("enabling time to live: %w", err)
+		}
+	}
+	if options.PointInTimeRecovery {
+		_, err := c.db.UpdateContinuousBackupsWithContext(ctx, &dynamodb.UpdateContinuousBackupsInput{
+			TableName: aws.String(c.tableName),
+			PointInTimeRecoverySpecification: &dynamodb.PointInTimeRecoverySpecification{
+				PointInTimeRecoveryEnabled: aws.Bool(true),
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("enabling point in time recovery: %w", err)
+		}
+	}
+	return nil
+}
+
+func (c *Client) createTable(ctx context.Context, options TableOptions, throughput *dynamodb.ProvisionedThroughput) (*dynamodb.TableDescription, error) {
+	input := &dynamodb.CreateTableInput{
+		TableName:             aws.String(c.tableName),
+		AttributeDefinitions:  []*dynamodb.AttributeDefinition{stringAttribute("id")},
+		KeySchema:             hashKey("id"),
+		BillingMode:           aws.String(options.BillingMode),
+		ProvisionedThroughput: throughput,
+	}
+	for _, index := range options.Indexes {
+		input.AttributeDefinitions = append(input.AttributeDefinitions, stringAttribute(index.Attribute))
+		input.GlobalSecondaryIndexes = append(input.GlobalSecondaryIndexes, &dynamodb.GlobalSecondaryIndex{
+			IndexName:             aws.String(index.Name),
+			KeySchema:             hashKey(index.Attribute),
+			Projection:            &dynamodb.Projection{ProjectionType: aws.String(dynamodb.ProjectionTypeAll)},
+			ProvisionedThroughput: throughput,
+		})
+	}
+	if options.StreamViewType != "" {
+		input.StreamSpecification = streamSpecification(options.StreamViewType)
+	}
+	if _, err := c.db.CreateTableWithContext(ctx, input); err != nil {
+		return nil, err
+	}
+	if err := c.waitForTable(ctx); err != nil {
+		return nil, err
+	}
+	return c.describeTable(ctx)
+}
+
+func (c *Client) waitForTable(ctx context.Context) error {
+	return c.db.WaitUntilTableExistsWithContext(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(c.tableName),
+	})
+}
+
+func (c *Client) enableTTL(ctx context.Context, attribute string) error {
+	resp, err := c.db.DescribeTimeToLiveWithContext(ctx, &dynamodb.DescribeTimeToLiveInput{
+		TableName: aws.String(c.tableName),
+	})
+	if err != nil {
+		return err
+	}
+	// enabling time to live again fails
+	if ttl := resp.TimeToLiveDescription; ttl != nil && aws.StringValue(ttl.TimeToLiveStatus) != dynamodb.TimeToLiveStatusDisabled {
+		if aws.StringValue(ttl.AttributeName) != attribute {
+			return conflictErrorf("time to live already uses the attribute %s", aws.StringValue(ttl.AttributeName))
+		}
+		return nil
+	}
+	_, err = c.db.UpdateTimeToLiveWithContext(ctx, &dynamodb.UpdateTimeToLiveInput{
+		TableName: aws.String(c.tableName),
+		TimeToLiveSpecification: &dynamodb.TimeToLiveSpecification{
+			AttributeName: aws.String(attribute),
+			Enabled:       aws.Bool(true),
+		},
+	})
+	return err
+}
+
+// describeTable returns the description of the table. The error is
+// ErrNotFound if it doesn't exist.
+func (c *Client) describeTable(ctx context.Context) (*dynamodb.TableDescription, error) {
+	resp, err := c.db.DescribeTableWithContext(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(c.tableName),
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeResourceNotFoundException {
+		return nil, notFoundErrorf("table %s doesn't exist", c.tableName)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return resp.Table, nil
+}
+
+// keyProblem returns what is wrong with the key of the table, if
+// anything. envi needs a string hash key named id and no range key.
+func keyProblem(table *dynamodb.TableDescription) string {
+	if len(table.KeySchema) != 1 || aws.StringValue(table.KeySchema[0].AttributeName) != "id" ||
+		aws.StringValue(table.KeySchema[0].KeyType) != dynamodb.KeyTypeHash {
+		return "the key must be a hash key named id and nothing else"
+	}
+	for _, attribute := range table.AttributeDefinitions {
+		if aws.StringValue(attribute.AttributeName) == "id" && aws.StringValue(attribute.AttributeType) != dynamodb.ScalarAttributeTypeS {
+			return "id must be a string"
+		}
+	}
+	return ""
+}
+
+func hasIndex(table *dynamodb.TableDescription, name string) bool {
+	for _, index := range table.GlobalSecondaryIndexes {
+		if aws.StringValue(index.IndexName) == name {
+			return true
+		}
+	}
+	return false
+}
+
+func stringAttribute(name string) *dynamodb.AttributeDefinition {
+	return &dynamodb.AttributeDefinition{
+		AttributeName: aws.String(name),
+		AttributeType: aws.String(dynamodb.ScalarAttributeTypeS),
+	}
+}
+
+func hashKey(name string) []*dynamodb.KeySchemaElement {
+	return []*dynamodb.KeySchemaElement{{
+		AttributeName: aws.String(name),
+		KeyType:       aws.String(dynamodb.KeyTypeHash),
+	}}
+}
+
+func streamSpecification(viewType string) *dynamodb.StreamSpecification {
+	return &dynamodb.StreamSpecification{
+		StreamEnabled:  aws.Bool(true),
+		StreamViewType: aws.String(viewType),
+	}
+}
+
+// Check is the result of one of the checks of Doctor
+type Check struct {
+	Name string
+	// Err is what is wrong, nil if the check passed
+	Err error
+}
+
+// doctorProbeID is the id used to check permissions. It is never
+// written.
+const doctorProbeID = "_doctor__probe"
+
+// Doctor checks that the table can be used by envi: that it can be
+// reached, has the right key and that the credentials can read, write
+// and delete items, also in transactions and batches. Checks that can't run because an earlier one failed are
+// left out.
+func Doctor() []Check {
+	return defaultClient.Doctor(context.Background())
+}
+
+// Doctor checks that the table can be used by envi. See the package
+// level Doctor.
+func (c *Client) Doctor(ctx context.Context) []Check {
+	table, err := c.describeTable(ctx)
+	checks := []Check{{Name: "connect to " + c.tableName, Err: err}}
+	if err != nil {
+		return checks
+	}
+
+	var status, keyErr error
+	if s := aws.StringValue(table.TableStatus); s != dynamodb.TableStatusActive {
+		status = fmt.Errorf("table is %s", s)
+	}
+	if problem := keyProblem(table); problem != "" {
+		keyErr = validationErrorf("%s", problem)
+	}
+	checks = append(checks, Check{Name: "table is active", Err: status}, Check{Name: "table key", Err: keyErr})
+
+	_, err = c.db.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(c.tableName),
+		Key:       key(doctorProbeID),
+	})
+	checks = append(checks, Check{Name: "read permission", Err: err})
+
+	_, err = c.db.ScanWithContext(ctx, &dynamodb.ScanInput{
+		TableName: aws.String(c.tableName),
+		Limit:     aws.Int64(1),
+	})
+	checks = append(checks, Check{Name: "scan permission", Err: err})
+
+	// the conditions fail for the probe, which doesn't exist, after the
+	// permissions have been checked so nothing is written
+	_, err = c.db.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(c.tableName),
+		Item:                key(doctorProbeID),
+		ConditionExpression: aws.String(itemExists.expression),
+	})
+	checks = append(checks, Check{Name: "write permission", Err: probeError(err, "overwritten")})
+
+	_, err = c.db.DeleteItemWithContext(ctx, &dynamodb.DeleteItemInput{
+		TableName:           aws.String(c.tableName),
+		Key:                 key(doctorProbeID),
+		ConditionExpression: aws.String(itemExists.expression),
+	})
+	checks = append(checks, Check{Name: "delete permission", Err: probeError(err, "deleted")})
+
+	_, err = c.db.TransactWriteItemsWithContext(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{{Put: &dynamodb.Put{
+			TableName:           aws.String(c.tableName),
+			Item:                key(doctorProbeID),
+			ConditionExpression: aws.String(itemExists.expression),
+		}}},
+	})
+	checks = append(checks, Check{Name: "transaction permission", Err: probeError(err, "overwritten")})
+
+	// batches can't have conditions but deleting the probe, which
+	// doesn't exist, changes nothing
+	_, err = c.db.BatchWriteItemWithContext(ctx, &dynamodb.BatchWriteItemInput{
+		RequestItems: map[string][]*dynamodb.WriteRequest{
+			c.tableName: {{DeleteRequest: &dynamodb.DeleteRequest{Key: key(doctorProbeID)}}},
+		},
+	})
+	checks = append(checks, Check{Name: "batch write permission", Err: err})
+	return checks
+}
+
+// probeError returns the error of a write of the doctor probe made
+// conditional on the probe existing, nil if only the condition failed
+func probeError(err error, written string) error {
+	if canceled, ok := err.(*dynamodb.TransactionCanceledException); ok && conditionFailed(canceled, 0) {
+		return nil
+	}
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return nil
+	}
+	if err == nil {
+		return fmt.Errorf("%s exists and was %s", doctorProbeID, written)
+	}
+	return err
+}
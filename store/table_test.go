@@ -0,0 +1,205 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// mockTableClient keeps the description of a single table
+type mockTableClient struct {
+	mockDynamoDBClient
+	table   **dynamodb.TableDescription
+	ttl     *string
+	updates *int
+}
+
+func newMockTableClient() mockTableClient {
+	return mockTableClient{
+		mockDynamoDBClient: mockDynamoDBClient{items: map[string]map[string]*dynamodb.AttributeValue{}},
+		table:              new(*dynamodb.TableDescription),
+		ttl:                new(string),
+		updates:            new(int),
+	}
+}
+
+func (m mockTableClient) DescribeTableWithContext(ctx aws.Context, input *dynamodb.DescribeTableInput, opts ...request.Option) (*dynamodb.DescribeTableOutput, error) {
+	if *m.table == nil {
+		return nil, awserr.New(dynamodb.ErrCodeResourceNotFoundException, "Requested resource not found", nil)
+	}
+	return &dynamodb.DescribeTableOutput{Table: *m.table}, nil
+}
+
+func (m mockTableClient) CreateTableWithContext(ctx aws.Context, input *dynamodb.CreateTableInput, opts ...request.Option) (*dynamodb.CreateTableOutput, error) {
+	*m.table = &dynamodb.TableDescription{
+		TableName:            input.TableName,
+		TableStatus:          aws.String(dynamodb.TableStatusActive),
+		KeySchema:            input.KeySchema,
+		AttributeDefinitions: input.AttributeDefinitions,
+		StreamSpecification:  input.StreamSpecification,
+	}
+	for _, index := range input.GlobalSecondaryIndexes {
+		(*m.table).GlobalSecondaryIndexes = append((*m.table).GlobalSecondaryIndexes, &dynamodb.GlobalSecondaryIndexDescription{IndexName: index.IndexName})
+	}
+	return &dynamodb.CreateTableOutput{TableDescription: *m.table}, nil
+}
+
+func (m mockTableClient) UpdateTableWithContext(ctx aws.Context, input *dynamodb.UpdateTableInput, opts ...request.Option) (*dynamodb.UpdateTableOutput, error) {
+	*m.updates++
+	for _, update := range input.GlobalSecondaryIndexUpdates {
+		(*m.table).GlobalSecondaryIndexes = append((*m.table).GlobalSecondaryIndexes, &dynamodb.GlobalSecondaryIndexDescription{IndexName: update.Create.IndexName})
+	}
+	if input.StreamSpecification != nil {
+		(*m.table).StreamSpecification = input.StreamSpecification
+	}
+	return &dynamodb.UpdateTableOutput{}, nil
+}
+
+func (m mockTableClient) WaitUntilTableExistsWithContext(ctx aws.Context, input *dynamodb.DescribeTableInput, opts ...request.WaiterOption) error {
+	return nil
+}
+
+func (m mockTableClient) DescribeTimeToLiveWithContext(ctx aws.Context, input *dynamodb.DescribeTimeToLiveInput, opts ...request.Option) (*dynamodb.DescribeTimeToLiveOutput, error) {
+	description := &dynamodb.TimeToLiveDescription{TimeToLiveStatus: aws.String(dynamodb.TimeToLiveStatusDisabled)}
+	if *m.ttl != "" {
+		description = &dynamodb.TimeToLiveDescription{TimeToLiveStatus: aws.String(dynamodb.TimeToLiveStatusEnabled), AttributeName: m.ttl}
+	}
+	return &dynamodb.DescribeTimeToLiveOutput{TimeToLiveDescription: description}, nil
+}
+
+func (m mockTableClient) UpdateTimeToLiveWithContext(ctx aws.Context, input *dynamodb.UpdateTimeToLiveInput, opts ...request.Option) (*dynamodb.UpdateTimeToLiveOutput, error) {
+	if *m.ttl != "" {
+		return nil, awserr.New("ValidationException", "TimeToLive is already enabled", nil)
+	}
+	*m.ttl = *input.TimeToLiveSpecification.AttributeName
+	return &dynamodb.UpdateTimeToLiveOutput{}, nil
+}
+
+func (m mockTableClient) UpdateContinuousBackupsWithContext(ctx aws.Context, input *dynamodb.UpdateContinuousBackupsInput, opts ...request.Option) (*dynamodb.UpdateContinuousBackupsOutput, error) {
+	return &dynamodb.UpdateContinuousBackupsOutput{}, nil
+}
+
+// PutItemWithContext only understands the attribute_exists(id) condition
+func (m mockTableClient) PutItemWithContext(ctx aws.Context, input *dynamodb.PutItemInput, opts ...request.Option) (*dynamodb.PutItemOutput, error) {
+	if _, exists := m.items[*input.Item["id"].S]; !exists && aws.StringValue(input.ConditionExpression) == "attribute_exists(id)" {
+		return nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "The conditional request failed", nil)
+	}
+	return m.mockDynamoDBClient.PutItemWithContext(ctx, input, opts...)
+}
+
+func TestCreateTable(t *testing.T) {
+	mock := newMockTableClient()
+	client, err := NewClient(WithTable("envi"), WithDB(mock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	options := TableOptions{
+		Indexes:             []IndexOptions{{Name: "by-app", Attribute: "app"}},
+		TTLAttribute:        "expires",
+		PointInTimeRecovery: true,
+		StreamViewType:      dynamodb.StreamViewTypeNewAndOldImages,
+	}
+	if err := client.CreateTable(ctx, options); err != nil {
+		t.Fatalf("error creating table %s", err)
+	}
+	if *mock.ttl != "expires" || len((*mock.table).GlobalSecondaryIndexes) != 1 {
+		t.Fatalf("expected table settings to be applied %v %s", *mock.table, *mock.ttl)
+	}
+
+	// running again changes nothing
+	if err := client.CreateTable(ctx, options); err != nil {
+		t.Fatalf("error creating existing table %s", err)
+	}
+	if *mock.updates != 0 {
+		t.Fatalf("expected no updates to an up to date table, got %d", *mock.updates)
+	}
+	// settings missing from an existing table are added
+	options.Indexes = append(options.Indexes, IndexOptions{Name: "by-env", Attribute: "env"})
+	if err := client.CreateTable(ctx, options); err != nil || len((*mock.table).GlobalSecondaryIndexes) != 2 {
+		t.Fatalf("expected index to be added %v", err)
+	}
+
+	if err := client.CreateTable(ctx, TableOptions{BillingMode: dynamodb.BillingModeProvisioned}); !errors.Is(err, ErrValidation) {
+		t.Fatalf("expected validation error without capacity, got %v", err)
+	}
+	(*mock.table).KeySchema = hashKey("name")
+	if err := client.CreateTable(ctx, TableOptions{}); !errors.Is(err, ErrConflict) {
+		t.Fatalf("expected conflict with a different key, got %v", err)
+	}
+}
+
+func TestDoctor(t *testing.T) {
+	mock := newMockTableClient()
+	client, err := NewClient(WithTable("envi"), WithDB(mock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	checks := client.Doctor(ctx)
+	if len(checks) != 1 || !errors.Is(checks[0].Err, ErrNotFound) {
+		t.Fatalf("expected only a failed connection check for a missing table %v", checks)
+	}
+
+	if err := client.CreateTable(ctx, TableOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, check := range client.Doctor(ctx) {
+		if check.Err != nil {
+			t.Fatalf("%s failed: %s", check.Name, check.Err)
+		}
+	}
+	if len(mock.items) != 0 {
+		t.Fatalf("expected doctor not to write anything")
+	}
+
+	(*mock.table).KeySchema = hashKey("name")
+	failed := 0
+	for _, check := range client.Doctor(ctx) {
+		if check.Err != nil {
+			failed++
+		}
+	}
+	if failed != 1 {
+		t.Fatalf("expected the key check to fail, %d checks failed", failed)
+	}
+}
+
+// deniedDynamoDBClient denies TransactWriteItems and BatchWriteItem
+type deniedDynamoDBClient struct {
+	mockTableClient
+}
+
+func (m deniedDynamoDBClient) TransactWriteItemsWithContext(ctx aws.Context, input *dynamodb.TransactWriteItemsInput, opts ...request.Option) (*dynamodb.TransactWriteItemsOutput, error) {
+	return nil, awserr.New("AccessDeniedException", "not authorized to perform: dynamodb:TransactWriteItems", nil)
+}
+
+func (m deniedDynamoDBClient) BatchWriteItemWithContext(ctx aws.Context, input *dynamodb.BatchWriteItemInput, opts ...request.Option) (*dynamodb.BatchWriteItemOutput, error) {
+	return nil, awserr.New("AccessDeniedException", "not authorized to perform: dynamodb:BatchWriteItem", nil)
+}
+
+func TestDoctorPermissions(t *testing.T) {
+	mock := newMockTableClient()
+	client, err := NewClient(WithTable("envi"), WithDB(deniedDynamoDBClient{mock}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := client.CreateTable(ctx, TableOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	var failed []string
+	for _, check := range client.Doctor(ctx) {
+		if check.Err != nil {
+			failed = append(failed, check.Name)
+		}
+	}
+	if len(failed) != 2 || failed[0] != "transaction permission" || failed[1] != "batch write permission" {
+		t.Fatalf("expected the transaction and batch write checks to fail, got %v", failed)
+	}
+}
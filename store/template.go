@@ -0,0 +1,74 @@
+package store
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strconv"
+	"text/template"
+)
+
+// templateFuncs returns the helper functions available in templates
+// rendered with the variables of the item
+func (item *Item) templateFuncs() template.FuncMap {
+	return template.FuncMap{
+		// lookup returns the value of a variable or an empty string
+		// if it doesn't exist. Useful with default since referencing
+		// a missing variable directly is an error.
+		"lookup": func(name string) string {
+			for _, variable := range item.Variables {
+				if variable.Name == name {
+					return variable.Value
+				}
+			}
+			return ""
+		},
+		"required": func(message string, value interface{}) (interface{}, error) {
+			if isEmptyTemplateValue(value) {
+				return nil, fmt.Errorf("%s", message)
+			}
+			return value, nil
+		},
+		"default": func(fallback, value interface{}) interface{} {
+			if isEmptyTemplateValue(value) {
+				return fallback
+			}
+			return value
+		},
+		"b64enc": func(value string) string {
+			return base64.StdEncoding.EncodeToString([]byte(value))
+		},
+		"quote": func(value string) string {
+			return strconv.Quote(value)
+		},
+	}
+}
+
+func isEmptyTemplateValue(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	if s, ok := value.(string); ok {
+		return s == ""
+	}
+	return false
+}
+
+// Render executes the go text/template 'text' with the variables of
+// the item and writes the result to w. Variables are referenced by name,
+// e.g. {{ .DB_HOST }}, and referencing a variable that doesn't exist is
+// an error.
+func (item *Item) Render(w io.Writer, name, text string) error {
+	tmpl, err := template.New(name).
+		Option("missingkey=error").
+		Funcs(item.templateFuncs()).
+		Parse(text)
+	if err != nil {
+		return err
+	}
+	data := make(map[string]string, len(item.Variables))
+	for _, variable := range item.Variables {
+		data[variable.Name] = variable.Value
+	}
+	return tmpl.Execute(w, data)
+}
@@ -0,0 +1,51 @@
+package store
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	var buffer bytes.Buffer
+	text := `one={{ .one }}
+three={{ quote .three }}
+five={{ b64enc .five }}
+port={{ lookup "port" | default "8080" }}
+`
+	err := testItemOne.Render(&buffer, "test", text)
+	if err != nil {
+		t.Fatalf("error rendering template: %s", err)
+	}
+	expected := `one=two
+three="four"
+five=c2l4
+port=8080
+`
+	if buffer.String() != expected {
+		t.Fatalf("rendered template doesn't match expected\n%s", buffer.String())
+	}
+}
+
+func TestRenderMissingKey(t *testing.T) {
+	var buffer bytes.Buffer
+	err := testItemOne.Render(&buffer, "test", "{{ .missing }}")
+	if err == nil {
+		t.Fatalf("expected error when referencing a missing variable")
+	}
+}
+
+func TestRenderRequired(t *testing.T) {
+	var buffer bytes.Buffer
+	err := testItemOne.Render(&buffer, "test", `{{ lookup "missing" | required "missing is required" }}`)
+	if err == nil {
+		t.Fatalf("expected error from required")
+	}
+	buffer.Reset()
+	err = testItemOne.Render(&buffer, "test", `{{ .one | required "one is required" }}`)
+	if err != nil {
+		t.Fatalf("error rendering template: %s", err)
+	}
+	if buffer.String() != "two" {
+		t.Fatalf("expected required to pass value through")
+	}
+}
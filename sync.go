@@ -0,0 +1,57 @@
+package main
+
+import (
+	"github.com/tskinn/envi/store"
+	"github.com/urfave/cli"
+)
+
+func syncCommand() cli.Command {
+	var prefix string
+	var prune, dryRun bool
+	command := cli.Command{
+		Name:      "sync",
+		Usage:     "make the stored application configurations match a directory of config files",
+		ArgsUsage: "<directory>",
+		Action: func(c *cli.Context) error {
+			dir := c.Args().First()
+			if dir == "" {
+				return usageErrorf("must provide a directory")
+			}
+			items, err := store.ReadConfigDir(dir)
+			if err != nil {
+				return err
+			}
+
+			if err := initStore(); err != nil {
+				return err
+			}
+			changes, err := store.PlanSync(items, prune, prefix)
+			if err != nil {
+				return err
+			}
+			if !printPlan(changes) || dryRun {
+				return nil
+			}
+			return store.ApplyChanges(changes)
+		},
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:        "prune",
+				Usage:       "delete configs that aren't in the directory",
+				Destination: &prune,
+			},
+			cli.StringFlag{
+				Name:        "prefix",
+				Value:       "",
+				Usage:       "only prune ids starting with prefix, e.g. app__",
+				Destination: &prefix,
+			},
+			cli.BoolFlag{
+				Name:        "dry-run",
+				Usage:       "only print the changes",
+				Destination: &dryRun,
+			},
+		},
+	}
+	return command
+}
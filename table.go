@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/tskinn/envi/store"
+	"github.com/urfave/cli"
+)
+
+func initTableCommand() cli.Command {
+	var options store.TableOptions
+	var billingMode string
+	command := cli.Command{
+		Name:  "init-table",
+		Usage: "create the dynamodb table, or add the settings missing from an existing one",
+		Action: func(c *cli.Context) error {
+			switch billingMode {
+			case "pay-per-request":
+				options.BillingMode = dynamodb.BillingModePayPerRequest
+			case "provisioned":
+				options.BillingMode = dynamodb.BillingModeProvisioned
+			default:
+				return usageErrorf("billing mode must be pay-per-request or provisioned")
+			}
+			for _, index := range c.StringSlice("index") {
+				parts := strings.SplitN(index, "=", 2)
+				if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+					return usageErrorf("bad index %q; must be name=attribute", index)
+				}
+				options.Indexes = append(options.Indexes, store.IndexOptions{Name: parts[0], Attribute: parts[1]})
+			}
+			if options.StreamViewType != "" && !slices.Contains(dynamodb.StreamViewType_Values(), options.StreamViewType) {
+				return usageErrorf("stream must be one of %s", strings.Join(dynamodb.StreamViewType_Values(), ", "))
+			}
+
+			if err := initStore(); err != nil {
+				return err
+			}
+			if err := store.CreateTable(options); err != nil {
+				return err
+			}
+			fmt.Printf("table %s is ready\n", tableName)
+			return nil
+		},
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:        "billing-mode",
+				Value:       "pay-per-request",
+				Usage:       "pay-per-request or provisioned",
+				Destination: &billingMode,
+			},
+			cli.Int64Flag{
+				Name:        "read-capacity",
+				Usage:       "read capacity units in the provisioned billing mode",
+				Destination: &options.ReadCapacity,
+			},
+			cli.Int64Flag{
+				Name:        "write-capacity",
+				Usage:       "write capacity units in the provisioned billing mode",
+				Destination: &options.WriteCapacity,
+			},
+			cli.StringSliceFlag{
+				Name:  "index",
+				Usage: "global secondary index keyed by a string attribute in the form of name=attribute; may be repeated",
+			},
+			cli.StringFlag{
+				Name:        "ttl-attribute",
+				Usage:       "enable time to live with the expiry time in this attribute",
+				Destination: &options.TTLAttribute,
+			},
+			cli.BoolFlag{
+				Name:        "point-in-time-recovery",
+				Usage:       "enable point in time recovery",
+				Destination: &options.PointInTimeRecovery,
+			},
+			cli.StringFlag{
+				Name:        "stream",
+				Usage:       "enable a stream with records of this view type, e.g. NEW_AND_OLD_IMAGES",
+				Destination: &options.StreamViewType,
+			},
+		},
+	}
+	return command
+}
+
+func doctorCommand() cli.Command {
+	command := cli.Command{
+		Name:  "doctor",
+		Usage: "check that the dynamodb table can be reached and used",
+		Action: func(c *cli.Context) error {
+			if err := initStore(); err != nil {
+				return err
+			}
+			var failed error
+			for _, check := range store.Doctor() {
+				if check.Err == nil {
+					fmt.Printf("ok    %s\n", check.Name)
+					continue
+				}
+				fmt.Printf("FAIL  %s: %s\n", check.Name, check.Err)
+				if failed == nil {
+					failed = fmt.Errorf("%s failed: %w", check.Name, check.Err)
+				}
+			}
+			return failed
+		},
+	}
+	return command
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/tskinn/envi/store"
+	"github.com/urfave/cli"
+)
+
+func validateCommand() cli.Command {
+	var schemaFile, prefix string
+	command := cli.Command{
+		Name:  "validate",
+		Usage: "check stored application configurations against the schemas of their applications",
+		Action: func(c *cli.Context) error {
+			if (id == "") == (prefix == "") {
+				return usageErrorf("must provide one of id or prefix")
+			}
+
+			// schemas by application; nil if the application has none
+			schemas := make(map[string]*store.Schema)
+			if schemaFile != "" {
+				file, err := os.Open(schemaFile)
+				if err != nil {
+					return err
+				}
+				defer file.Close()
+				schema, err := store.ParseSchema(file)
+				if err != nil {
+					return err
+				}
+				// the file applies to every config
+				schemas[""] = &schema
+			}
+			schemaOf := func(app string) (*store.Schema, error) {
+				if schema, found := schemas[""]; found {
+					return schema, nil
+				}
+				if schema, found := schemas[app]; found {
+					return schema, nil
+				}
+				schema, _, err := store.GetSchema(app)
+				if errors.Is(err, store.ErrNotFound) {
+					schemas[app] = nil
+					return nil, nil
+				}
+				if err != nil {
+					return nil, err
+				}
+				schemas[app] = &schema
+				return &schema, nil
+			}
+
+			if err := initStore(); err != nil {
+				return err
+			}
+			var items []store.Item
+			if id != "" {
+				item, err := store.Get(id)
+				if err != nil {
+					return err
+				}
+				items = append(items, item)
+			} else {
+				var err error
+				if items, err = store.List(prefix); err != nil {
+					return err
+				}
+			}
+
+			checked, invalid := 0, 0
+			for _, item := range items {
+				schema, err := schemaOf(store.AppFromID(item.ID))
+				if err != nil {
+					return err
+				}
+				if schema == nil {
+					if id != "" {
+						return fmt.Errorf("%s has no schema: %w", store.AppFromID(id), store.ErrNotFound)
+					}
+					continue
+				}
+				checked++
+				problems := schema.Check(item.Variables)
+				for _, problem := range problems {
+					fmt.Printf("%s: %s\n", item.ID, problem)
+				}
+				if len(problems) > 0 {
+					invalid++
+				}
+			}
+			if invalid > 0 {
+				return fmt.Errorf("%d of %d configs don't match their schemas: %w", invalid, checked, store.ErrValidation)
+			}
+			fmt.Fprintf(os.Stderr, "%d configs are valid\n", checked)
+			return nil
+		},
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:        "prefix",
+				Value:       "",
+				Usage:       "validate every config whose id starts with prefix, e.g. app__",
+				Destination: &prefix,
+			},
+			cli.StringFlag{
+				Name:        "schema-file",
+				Value:       "",
+				Usage:       "validate against this yaml schema instead of the registered schemas",
+				Destination: &schemaFile,
+			},
+		},
+	}
+	return command
+}